@@ -3,6 +3,7 @@ package electrum
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -10,32 +11,97 @@ import (
 )
 
 // AddressToElectrumScriptHash converts valid bitcoin address to electrum scriptHash sha256 encoded, reversed and encoded in hex
+// The address is decoded for mainnet unless network params are given.
 // https://electrumx.readthedocs.io/en/latest/protocol-basics.html#script-hashes
-func AddressToElectrumScriptHash(addressStr string) (string, error) {
-	address, err := btcutil.DecodeAddress(addressStr, &chaincfg.MainNetParams)
+func AddressToElectrumScriptHash(
+	addressStr string,
+	params ...*chaincfg.Params,
+) (string, error) {
+	network := &chaincfg.MainNetParams
+	if len(params) > 0 && params[0] != nil {
+		network = params[0]
+	}
+
+	address, err := btcutil.DecodeAddress(addressStr, network)
 	if err != nil {
 		return "", err
 	}
+	// Segwit addresses of any known network are decoded regardless of params.
+	if !address.IsForNet(network) {
+		return "", fmt.Errorf("address %s is not for %s", addressStr, network.Name)
+	}
 	script, err := txscript.PayToAddrScript(address)
 	if err != nil {
 		return "", err
 	}
 
+	return ScriptToElectrumScriptHash(script), nil
+}
+
+// ScriptToElectrumScriptHash converts a raw scriptPubKey to an electrum
+// scripthash, covering outputs without an address.
+func ScriptToElectrumScriptHash(script []byte) string {
 	hashSum := sha256.Sum256(script)
+	return hex.EncodeToString(ReverseBytes(hashSum[:]))
+}
 
-	for i, j := 0, len(hashSum)-1; i < j; i, j = i+1, j-1 {
-		hashSum[i], hashSum[j] = hashSum[j], hashSum[i]
+// PubKeyToElectrumScriptHash converts a serialized public key to the electrum
+// scripthash of its pay-to-pubkey output.
+func PubKeyToElectrumScriptHash(pubKey []byte) (string, error) {
+	address, err := btcutil.NewAddressPubKey(pubKey, &chaincfg.MainNetParams)
+	if err != nil {
+		return "", err
+	}
+
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return "", err
 	}
 
-	return hex.EncodeToString(hashSum[:]), nil
+	return ScriptToElectrumScriptHash(script), nil
+}
+
+// MultiSigToElectrumScriptHash converts serialized public keys to the electrum
+// scripthash of their bare multisig output requiring nRequired signatures.
+func MultiSigToElectrumScriptHash(
+	nRequired int,
+	pubKeys ...[]byte,
+) (string, error) {
+	addresses := make([]*btcutil.AddressPubKey, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		address, err := btcutil.NewAddressPubKey(
+			pubKey,
+			&chaincfg.MainNetParams,
+		)
+		if err != nil {
+			return "", err
+		}
+		addresses = append(addresses, address)
+	}
+
+	script, err := txscript.MultiSigScript(addresses, nRequired)
+	if err != nil {
+		return "", err
+	}
+
+	return ScriptToElectrumScriptHash(script), nil
+}
+
+// AddressToElectrumScriptHash converts an address of the client network, or
+// of the given network params, to an electrum scripthash.
+func (s *Client) AddressToElectrumScriptHash(
+	address string,
+	params ...*chaincfg.Params,
+) (string, error) {
+	return AddressToElectrumScriptHash(address, s.network(params))
 }
 
 // GetTotalSentAndReceived returns the total sent and received for a scripthash.
 func GetTotalSentAndReceived(
 	address string,
 	history []*DetailedMempoolResult,
-) (float64, float64) {
-	var totalSent, totalReceived float64
+) (btcutil.Amount, btcutil.Amount) {
+	var totalSent, totalReceived btcutil.Amount
 	for _, tx := range history {
 		if tx.Incoming {
 			findAddressFunc[Vout](
@@ -51,7 +117,7 @@ func GetTotalSentAndReceived(
 				address,
 				tx.Vin,
 				func(elem VinWithPrevout, index int) bool {
-					totalSent += elem.Prevout.Value
+					totalSent += elem.Value
 					return true
 				},
 			)
@@ -3,6 +3,7 @@ package electrum
 import (
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -10,6 +11,7 @@ import (
 func TestAddressToElectrumScriptHash(t *testing.T) {
 	tests := []struct {
 		address        string
+		params         *chaincfg.Params
 		wantScriptHash string
 	}{
 		{
@@ -20,11 +22,73 @@ func TestAddressToElectrumScriptHash(t *testing.T) {
 			address:        "34xp4vRoCGJym3xR7yCVPFHoCNxv4Twseo",
 			wantScriptHash: "2375f2bbf7815e3cdc835074b052d65c9b2f101bab28d37250cc96b2ed9a6809",
 		},
+		{
+			address:        "mpXwg4jMtRhuSpVq4xS3HFHmCmWp9NyGKt",
+			params:         &chaincfg.TestNet3Params,
+			wantScriptHash: "8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161",
+		},
+		{
+			address:        "tb1qvt5s0v2uhuna2sjnn84ldu8m2r4m3rcclfw5ch",
+			params:         &TestNet4Params,
+			wantScriptHash: "45dc3792fc06ee8c3b3e27ae390747f663ef0ac933aa29ade0ed0a390186bdfc",
+		},
+		{
+			address:        "tb1qvt5s0v2uhuna2sjnn84ldu8m2r4m3rcclfw5ch",
+			params:         &chaincfg.SigNetParams,
+			wantScriptHash: "45dc3792fc06ee8c3b3e27ae390747f663ef0ac933aa29ade0ed0a390186bdfc",
+		},
+		{
+			address:        "bcrt1qvt5s0v2uhuna2sjnn84ldu8m2r4m3rccaqhe07",
+			params:         &chaincfg.RegressionNetParams,
+			wantScriptHash: "45dc3792fc06ee8c3b3e27ae390747f663ef0ac933aa29ade0ed0a390186bdfc",
+		},
 	}
 
 	for _, tc := range tests {
-		scriptHash, err := AddressToElectrumScriptHash(tc.address)
+		scriptHash, err := AddressToElectrumScriptHash(tc.address, tc.params)
 		require.NoError(t, err)
 		assert.Equal(t, scriptHash, tc.wantScriptHash)
 	}
+
+	_, err := AddressToElectrumScriptHash(
+		"bcrt1qvt5s0v2uhuna2sjnn84ldu8m2r4m3rccaqhe07",
+	)
+	assert.Error(t, err)
+
+	client := &Client{params: &chaincfg.RegressionNetParams}
+	scriptHash, err := client.AddressToElectrumScriptHash(
+		"bcrt1qvt5s0v2uhuna2sjnn84ldu8m2r4m3rccaqhe07",
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"45dc3792fc06ee8c3b3e27ae390747f663ef0ac933aa29ade0ed0a390186bdfc",
+		scriptHash,
+	)
+}
+
+func TestNetworkByName(t *testing.T) {
+	for _, name := range []string{"mainnet", "testnet3", "testnet4", "signet", "regtest"} {
+		params, err := NetworkByName(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, params.Name)
+	}
+
+	assert.Equal(
+		t,
+		TestNet4Params.GenesisHash.String(),
+		TestNet4Params.GenesisBlock.Header.BlockHash().String(),
+	)
+
+	_, err := NetworkByName("custom")
+	assert.ErrorIs(t, err, ErrUnknownNetwork)
+
+	custom := chaincfg.CustomSignetParams([]byte{0x51}, nil)
+	custom.Name = "custom"
+	require.NoError(t, RegisterNetwork(&custom))
+	assert.ErrorIs(t, RegisterNetwork(&custom), ErrNetworkRegistered)
+
+	params, err := NetworkByName("custom")
+	require.NoError(t, err)
+	assert.Equal(t, &custom, params)
 }
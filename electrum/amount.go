@@ -0,0 +1,82 @@
+package electrum
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// ParseBTCAmount converts a decimal BTC value, as found in verbose
+// transactions, to satoshis without going through float64. Values with more
+// than 8 decimals are rejected.
+func ParseBTCAmount(value string) (btcutil.Amount, error) {
+	btc, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("invalid BTC amount %q", value)
+	}
+
+	sats := btc.Mul(btc, big.NewRat(btcutil.SatoshiPerBitcoin, 1))
+	if !sats.IsInt() || !sats.Num().IsInt64() {
+		return 0, fmt.Errorf("invalid BTC amount %q", value)
+	}
+
+	return btcutil.Amount(sats.Num().Int64()), nil
+}
+
+// FormatBTCAmount formats satoshis as a decimal BTC value with 8 decimals.
+func FormatBTCAmount(amount btcutil.Amount) string {
+	sign := ""
+	sats := int64(amount)
+	if sats < 0 {
+		sign = "-"
+		sats = -sats
+	}
+
+	return fmt.Sprintf(
+		"%s%d.%08d",
+		sign,
+		sats/btcutil.SatoshiPerBitcoin,
+		sats%btcutil.SatoshiPerBitcoin,
+	)
+}
+
+// vout has the fields of Vout without its JSON methods.
+type vout Vout
+
+// MarshalJSON encodes the value in BTC, like the remote server does.
+func (v Vout) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		vout
+		Value json.Number `json:"value"`
+	}{
+		vout:  vout(v),
+		Value: json.Number(FormatBTCAmount(v.Value)),
+	})
+}
+
+// UnmarshalJSON decodes the value in BTC to satoshis losslessly.
+func (v *Vout) UnmarshalJSON(b []byte) error {
+	aux := struct {
+		*vout
+		Value json.Number `json:"value"`
+	}{
+		vout: (*vout)(v),
+	}
+
+	err := json.Unmarshal(b, &aux)
+	if err != nil {
+		return err
+	}
+
+	v.Value = 0
+	if aux.Value != "" {
+		v.Value, err = ParseBTCAmount(aux.Value.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package electrum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBTCAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantSats btcutil.Amount
+	}{
+		{"0.1", 10000000},
+		{"0.29", 29000000},
+		{"1e-05", 1000},
+		{"20999999.97690000", 2099999997690000},
+		{"-0.00000001", -1},
+		{"0", 0},
+	}
+
+	for _, tc := range tests {
+		sats, err := ParseBTCAmount(tc.value)
+		require.NoError(t, err)
+		assert.Equal(t, tc.wantSats, sats)
+	}
+
+	_, err := ParseBTCAmount("0.000000001")
+	assert.Error(t, err)
+
+	assert.Equal(t, "0.29000000", FormatBTCAmount(29000000))
+	assert.Equal(t, "-0.00000001", FormatBTCAmount(-1))
+}
+
+func TestVoutJSON(t *testing.T) {
+	var vout Vout
+	err := json.Unmarshal(
+		[]byte(`{"n":1,"scriptPubKey":{"asm":"","type":"nonstandard"},"value":0.29}`),
+		&vout,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), vout.N)
+	assert.Equal(t, btcutil.Amount(29000000), vout.Value)
+
+	b, err := json.Marshal(vout)
+	require.NoError(t, err)
+
+	var decoded Vout
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, vout, decoded)
+}
@@ -0,0 +1,377 @@
+package electrum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+)
+
+// maxBatchSize bounds the number of calls the client packs in a single batch
+// for its own bulk lookups, keeping responses under the server size limits.
+const maxBatchSize = 100
+
+var (
+	// ErrBatchNotSent throws an error if the result of a batch call is read
+	// before the batch has been sent.
+	ErrBatchNotSent = errors.New("batch has not been sent")
+
+	// ErrMissingResponse throws an error if the server replied to a batch
+	// without a response to the call.
+	ErrMissingResponse = errors.New("no response to the call in batch reply")
+)
+
+// Batch queues several calls to send them to the remote server as a single
+// JSON-RPC batch request. A Batch must not be reused once sent.
+type Batch struct {
+	client *Client
+	calls  []*batchEntry
+}
+
+type batchEntry struct {
+	request request
+	resolve func(resp *container)
+}
+
+// pendingBatch is a batch request awaiting its reply.
+type pendingBatch struct {
+	ids      map[uint64]struct{}
+	rejected chan *RPCError
+}
+
+// BatchCall holds the result of a call queued in a Batch, available once the
+// batch has been sent.
+type BatchCall[T any] struct {
+	result T
+	err    error
+}
+
+// Result returns the result of the call, or the error the server answered with.
+func (c *BatchCall[T]) Result() (T, error) {
+	return c.result, c.err
+}
+
+// NewBatch creates an empty batch of calls to the remote server.
+func (s *Client) NewBatch() *Batch {
+	return &Batch{client: s}
+}
+
+// Len returns the number of queued calls.
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// batchAdd queues a call whose response is decoded into R and converted into
+// the call result by extract.
+func batchAdd[R any, T any](
+	b *Batch,
+	method string,
+	params []interface{},
+	extract func(resp *R) T,
+) *BatchCall[T] {
+	call := &BatchCall[T]{err: ErrBatchNotSent}
+
+	b.calls = append(b.calls, &batchEntry{
+		request: request{
+			Method: method,
+			Params: params,
+		},
+		resolve: func(resp *container) {
+			if resp.err != nil {
+				call.err = withMethod(resp.err, method)
+				return
+			}
+
+			var r R
+			err := json.Unmarshal(resp.content, &r)
+			if err != nil {
+				call.err = err
+				return
+			}
+
+			call.result, call.err = extract(&r), nil
+		},
+	})
+
+	return call
+}
+
+// Send sends all queued calls as a single request and waits for every
+// response. Per-call errors are reported by each BatchCall, the returned
+// error only covers the batch as a whole. Servers rejecting batch requests
+// are sent the calls separately instead.
+func (b *Batch) Send(ctx context.Context) error {
+	s := b.client
+	calls := b.calls
+	b.calls = nil
+
+	if len(calls) == 0 {
+		return nil
+	}
+
+	select {
+	case <-s.quit:
+		return ErrServerShutdown
+	default:
+	}
+
+	handlers := make([]chan *container, len(calls))
+	for i, call := range calls {
+		call.request.ID = atomic.AddUint64(&s.nextID, 1)
+		handlers[i] = s.registerHandler(call.request.ID)
+	}
+
+	defer func() {
+		for _, call := range calls {
+			s.unregisterHandler(call.request.ID)
+		}
+	}()
+
+	var rejected chan *RPCError
+	if atomic.LoadInt32(&s.noBatch) == 1 {
+		err := s.sendEach(calls)
+		if err != nil {
+			return err
+		}
+	} else {
+		batch := s.addPendingBatch(calls)
+		defer s.removePendingBatch(batch)
+		rejected = batch.rejected
+
+		msgs := make([]request, len(calls))
+		for i, call := range calls {
+			msgs[i] = call.request
+		}
+
+		bytes, err := json.Marshal(msgs)
+		if err != nil {
+			return err
+		}
+
+		bytes = append(bytes, nl)
+
+		err = s.send(bytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(calls); {
+		select {
+		case resp := <-handlers[i]:
+			calls[i].resolve(resp)
+			i++
+		case rpcErr := <-rejected:
+			s.logger.Debugf(
+				"Batch request rejected, sending calls separately: %v",
+				rpcErr,
+			)
+			atomic.StoreInt32(&s.noBatch, 1)
+			rejected = nil
+
+			err := s.sendEach(calls[i:])
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			for _, call := range calls[i:] {
+				call.resolve(&container{err: ErrTimeout})
+			}
+			return ErrTimeout
+		}
+	}
+
+	return nil
+}
+
+// sendEach sends calls as separate requests, to servers rejecting batches.
+func (s *Client) sendEach(calls []*batchEntry) error {
+	for _, call := range calls {
+		bytes, err := json.Marshal(call.request)
+		if err != nil {
+			return err
+		}
+
+		err = s.send(append(bytes, nl))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Client) addPendingBatch(calls []*batchEntry) *pendingBatch {
+	batch := &pendingBatch{
+		ids:      make(map[uint64]struct{}, len(calls)),
+		rejected: make(chan *RPCError, 1),
+	}
+	for _, call := range calls {
+		batch.ids[call.request.ID] = struct{}{}
+	}
+
+	s.batchesLock.Lock()
+	s.batches[batch] = struct{}{}
+	s.batchesLock.Unlock()
+
+	return batch
+}
+
+func (s *Client) removePendingBatch(batch *pendingBatch) {
+	s.batchesLock.Lock()
+	delete(s.batches, batch)
+	s.batchesLock.Unlock()
+}
+
+// completeBatches fails the calls of the batches answered by a reply holding
+// ids, when the reply has no response to them.
+func (s *Client) completeBatches(ids map[uint64]struct{}) {
+	s.batchesLock.Lock()
+	defer s.batchesLock.Unlock()
+
+	for batch := range s.batches {
+		answered := false
+		for id := range ids {
+			if _, ok := batch.ids[id]; ok {
+				answered = true
+				break
+			}
+		}
+		if !answered {
+			continue
+		}
+
+		delete(s.batches, batch)
+
+		s.handlersLock.RLock()
+		for id := range batch.ids {
+			if _, ok := ids[id]; ok {
+				continue
+			}
+			if c, ok := s.handlers[id]; ok {
+				select {
+				case c <- &container{err: ErrMissingResponse}:
+				default:
+				}
+			}
+		}
+		s.handlersLock.RUnlock()
+	}
+}
+
+// rejectBatches notifies the pending batches the server rejected a batch
+// request. The error does not tell which one, so all of them fall back to
+// separate requests.
+func (s *Client) rejectBatches(err *RPCError) {
+	s.batchesLock.Lock()
+	defer s.batchesLock.Unlock()
+
+	for batch := range s.batches {
+		delete(s.batches, batch)
+		batch.rejected <- err
+	}
+}
+
+// GetBalance queues a GetBalance call.
+func (b *Batch) GetBalance(scripthash string) *BatchCall[GetBalanceResult] {
+	return batchAdd(
+		b,
+		"blockchain.scripthash.get_balance",
+		[]interface{}{scripthash},
+		func(resp *GetBalanceResp) GetBalanceResult {
+			return resp.Result
+		},
+	)
+}
+
+// GetHistory queues a GetHistory call.
+func (b *Batch) GetHistory(scripthash string) *BatchCall[[]*GetMempoolResult] {
+	return batchAdd(
+		b,
+		"blockchain.scripthash.get_history",
+		[]interface{}{scripthash},
+		func(resp *GetMempoolResp) []*GetMempoolResult {
+			return resp.Result
+		},
+	)
+}
+
+// GetMempool queues a GetMempool call.
+func (b *Batch) GetMempool(scripthash string) *BatchCall[[]*GetMempoolResult] {
+	return batchAdd(
+		b,
+		"blockchain.scripthash.get_mempool",
+		[]interface{}{scripthash},
+		func(resp *GetMempoolResp) []*GetMempoolResult {
+			return resp.Result
+		},
+	)
+}
+
+// ListUnspent queues a ListUnspent call.
+func (b *Batch) ListUnspent(
+	scripthash string,
+) *BatchCall[[]*ListUnspentResult] {
+	return batchAdd(
+		b,
+		"blockchain.scripthash.listunspent",
+		[]interface{}{scripthash},
+		func(resp *ListUnspentResp) []*ListUnspentResult {
+			return resp.Result
+		},
+	)
+}
+
+// GetTransaction queues a GetTransaction call. Unlike Client.GetTransaction
+// it bypasses the transaction cache.
+func (b *Batch) GetTransaction(
+	txHash string,
+) *BatchCall[*GetTransactionResult] {
+	return batchAdd(
+		b,
+		"blockchain.transaction.get",
+		[]interface{}{txHash, true},
+		func(resp *GetTransactionResp) *GetTransactionResult {
+			return resp.Result
+		},
+	)
+}
+
+// GetRawTransaction queues a GetRawTransaction call.
+func (b *Batch) GetRawTransaction(txHash string) *BatchCall[string] {
+	return batchAdd(
+		b,
+		"blockchain.transaction.get",
+		[]interface{}{txHash, false},
+		func(resp *basicResp) string {
+			return resp.Result
+		},
+	)
+}
+
+// GetMerkleProof queues a GetMerkleProof call.
+func (b *Batch) GetMerkleProof(
+	txHash string,
+	height uint32,
+) *BatchCall[*GetMerkleProofResult] {
+	return batchAdd(
+		b,
+		"blockchain.transaction.get_merkle",
+		[]interface{}{txHash, height},
+		func(resp *GetMerkleProofResp) *GetMerkleProofResult {
+			return resp.Result
+		},
+	)
+}
+
+// GetBlockHeader queues a GetBlockHeader call without checkpoint.
+func (b *Batch) GetBlockHeader(height uint64) *BatchCall[string] {
+	return batchAdd(
+		b,
+		"blockchain.block.header",
+		[]interface{}{height, 0},
+		func(resp *basicResp) string {
+			return resp.Result
+		},
+	)
+}
@@ -0,0 +1,126 @@
+package electrum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBalanceServer answers get_balance with the balances of known
+// scripthashes and an error for unknown ones.
+func newBalanceServer(balances map[string]int64) *fakeServer {
+	server := newFakeServer()
+	server.on(
+		"blockchain.scripthash.get_balance",
+		func(params []interface{}) (interface{}, error) {
+			balance, ok := balances[params[0].(string)]
+			if !ok {
+				return nil, &RPCError{
+					Code:    CodeInvalidParams,
+					Message: "invalid scripthash",
+				}
+			}
+			return map[string]int64{"confirmed": balance}, nil
+		},
+	)
+	return server
+}
+
+func sendBalances(
+	t *testing.T,
+	client *Client,
+	scripthashes ...string,
+) []*BatchCall[GetBalanceResult] {
+	batch := client.NewBatch()
+	calls := make([]*BatchCall[GetBalanceResult], len(scripthashes))
+	for i, scripthash := range scripthashes {
+		calls[i] = batch.GetBalance(scripthash)
+	}
+	require.NoError(t, batch.Send(context.Background()))
+	return calls
+}
+
+func TestBatchOutOfOrder(t *testing.T) {
+	server := newBalanceServer(map[string]int64{"a": 1, "b": 2, "c": 3})
+	server.batchReply = func(answers []json.RawMessage) []json.RawMessage {
+		for i, j := 0, len(answers)-1; i < j; i, j = i+1, j-1 {
+			answers[i], answers[j] = answers[j], answers[i]
+		}
+		return answers
+	}
+	client := newFakeClient(t, server)
+
+	calls := sendBalances(t, client, "a", "b", "c")
+	for i, call := range calls {
+		balance, err := call.Result()
+		require.NoError(t, err)
+		assert.EqualValues(t, i+1, balance.Confirmed)
+	}
+	assert.Equal(t, 3, server.calls("blockchain.scripthash.get_balance"))
+}
+
+func TestBatchCallError(t *testing.T) {
+	server := newBalanceServer(map[string]int64{"a": 1, "c": 3})
+	client := newFakeClient(t, server)
+
+	calls := sendBalances(t, client, "a", "unknown", "c")
+
+	balance, err := calls[0].Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, balance.Confirmed)
+
+	_, err = calls[1].Result()
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeInvalidParams, rpcErr.Code)
+	assert.Equal(t, "blockchain.scripthash.get_balance", rpcErr.Method)
+
+	balance, err = calls[2].Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, balance.Confirmed)
+}
+
+func TestBatchMissingResponse(t *testing.T) {
+	server := newBalanceServer(map[string]int64{"a": 1, "b": 2, "c": 3})
+	server.batchReply = func(answers []json.RawMessage) []json.RawMessage {
+		return append(answers[:1], answers[2:]...)
+	}
+	client := newFakeClient(t, server)
+
+	calls := sendBalances(t, client, "a", "b", "c")
+
+	balance, err := calls[0].Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, balance.Confirmed)
+
+	_, err = calls[1].Result()
+	assert.ErrorIs(t, err, ErrMissingResponse)
+
+	balance, err = calls[2].Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, balance.Confirmed)
+}
+
+func TestBatchRejected(t *testing.T) {
+	server := newBalanceServer(map[string]int64{"a": 1, "b": 2})
+	server.noBatch = true
+	client := newFakeClient(t, server)
+
+	calls := sendBalances(t, client, "a", "b")
+	for i, call := range calls {
+		balance, err := call.Result()
+		require.NoError(t, err)
+		assert.EqualValues(t, i+1, balance.Confirmed)
+	}
+	assert.Equal(t, 2, server.calls("blockchain.scripthash.get_balance"))
+
+	// Later batches are sent separately right away.
+	calls = sendBalances(t, client, "b")
+	balance, err := calls[0].Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, balance.Confirmed)
+	assert.Equal(t, 3, server.calls("blockchain.scripthash.get_balance"))
+}
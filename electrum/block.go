@@ -3,6 +3,7 @@ package electrum
 import (
 	"context"
 	"errors"
+	"fmt"
 )
 
 var (
@@ -25,6 +26,9 @@ type GetBlockHeaderResult struct {
 }
 
 // GetBlockHeader returns the block header at a specific height.
+// When checkpointHeight is set, the returned branch and root are verified.
+// Unless the root of the checkpoint is set with WithCheckpoints, this only
+// proves the server is consistent with itself.
 // https://electrumx.readthedocs.io/en/latest/protocol-methods.html#blockchain-block-header
 func (s *Client) GetBlockHeader(
 	ctx context.Context,
@@ -43,8 +47,25 @@ func (s *Client) GetBlockHeader(
 			[]interface{}{height, checkpointHeight[0]},
 			&resp,
 		)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Result == nil {
+			return nil, ErrCheckpointMismatch
+		}
+
+		err = s.verifyCheckpoint(
+			resp.Result.Header,
+			height,
+			checkpointHeight[0],
+			resp.Result.Branch,
+			resp.Result.Root,
+		)
+		if err != nil {
+			return nil, err
+		}
 
-		return resp.Result, err
+		return resp.Result, nil
 	}
 
 	var resp basicResp
@@ -82,6 +103,10 @@ type GetBlockHeadersResult struct {
 }
 
 // GetBlockHeaders return a concatenated chunk of block headers.
+// When checkpointHeight is set, the headers are checked to be linked to each
+// other and the returned branch and root are verified for the last one.
+// Unless the root of the checkpoint is set with WithCheckpoints, this only
+// proves the server is consistent with itself.
 // https://electrumx.readthedocs.io/en/latest/protocol-methods.html#blockchain-block-headers
 func (s *Client) GetBlockHeaders(ctx context.Context, startHeight, count uint64,
 	checkpointHeight ...uint64) (*GetBlockHeadersResult, error) {
@@ -100,6 +125,13 @@ func (s *Client) GetBlockHeaders(ctx context.Context, startHeight, count uint64,
 			[]interface{}{startHeight, count, checkpointHeight[0]},
 			&resp,
 		)
+		if err == nil {
+			err = s.verifyCheckpointHeaders(
+				resp.Result,
+				startHeight,
+				checkpointHeight[0],
+			)
+		}
 	} else {
 		err = s.request(ctx, "blockchain.block.headers", []interface{}{startHeight, count, 0}, &resp)
 	}
@@ -110,3 +142,47 @@ func (s *Client) GetBlockHeaders(ctx context.Context, startHeight, count uint64,
 
 	return resp.Result, err
 }
+
+// verifyCheckpointHeaders checks that a chunk of headers is linked and that
+// its last header leads to the checkpoint root.
+func (s *Client) verifyCheckpointHeaders(
+	result *GetBlockHeadersResult,
+	startHeight, checkpointHeight uint64,
+) error {
+	if result == nil || result.Count == 0 {
+		return nil
+	}
+
+	headers, err := ParseBlockHeaders(result.Headers)
+	if err != nil {
+		return err
+	}
+
+	if len(headers) != int(result.Count) {
+		return fmt.Errorf(
+			"%w: expected %d headers, got %d",
+			ErrCheckpointMismatch,
+			result.Count,
+			len(headers),
+		)
+	}
+
+	for i := 1; i < len(headers); i++ {
+		if headers[i].PrevBlock != headers[i-1].BlockHash() {
+			return fmt.Errorf(
+				"%w: header %d",
+				ErrHeaderPrevHash,
+				startHeight+uint64(i),
+			)
+		}
+	}
+
+	last := len(result.Headers) - blockHeaderSize*2
+	return s.verifyCheckpoint(
+		result.Headers[last:],
+		startHeight+uint64(len(headers)-1),
+		checkpointHeight,
+		result.Branch,
+		result.Root,
+	)
+}
@@ -0,0 +1,262 @@
+package electrum
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+)
+
+// defaultTxCacheSize is the number of transactions kept by the default
+// in-memory cache of a client.
+const defaultTxCacheSize = 10000
+
+// TxCacheStore caches transactions and detailed transactions by id. A
+// detailed transaction is never overwritten by a plain one, and loading a
+// *DetailedTransaction only succeeds from a detailed entry. Clients prefix
+// ids with the genesis hash of their network.
+type TxCacheStore interface {
+	Store(txID string, tx any) error
+	Load(txID string, tx any) (ok bool)
+	Delete(txID string) error
+	Close() error
+}
+
+var (
+	_ TxCacheStore = (*TxCache)(nil)
+	_ TxCacheStore = (*MemoryTxCache)(nil)
+	_ TxCacheStore = NoopTxCache{}
+)
+
+// WithTxCache sets the transaction cache of the client, an in-memory LRU of
+// 10000 transactions by default. The store is not closed on Shutdown, so it
+// can be shared between clients.
+func WithTxCache(store TxCacheStore) ClientOption {
+	return func(c *Client) {
+		c.txCache = store
+	}
+}
+
+// NewSQLiteTxCache opens or creates a SQLite transaction cache at path.
+func NewSQLiteTxCache(path string) (*TxCache, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := NewTxCache(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+func isDetailedTx(tx any) bool {
+	switch tx.(type) {
+	case *DetailedTransaction, DetailedTransaction:
+		return true
+	}
+	return false
+}
+
+type memoryTxCacheEntry struct {
+	txID       string
+	data       []byte
+	isDetailed bool
+}
+
+// MemoryTxCache is an in-memory transaction cache evicting the least
+// recently used transactions.
+type MemoryTxCache struct {
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+}
+
+// NewMemoryTxCache creates an in-memory cache of at most size transactions.
+func NewMemoryTxCache(size int) *MemoryTxCache {
+	return &MemoryTxCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *MemoryTxCache) Store(txID string, tx any) error {
+	b, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+
+	isDetailed := isDetailedTx(tx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[txID]; ok {
+		entry := elem.Value.(*memoryTxCacheEntry)
+		if entry.isDetailed && !isDetailed {
+			return nil
+		}
+		entry.data = b
+		entry.isDetailed = isDetailed
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[txID] = c.lru.PushFront(&memoryTxCacheEntry{
+		txID:       txID,
+		data:       b,
+		isDetailed: isDetailed,
+	})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryTxCacheEntry).txID)
+	}
+
+	return nil
+}
+
+func (c *MemoryTxCache) Load(txID string, tx any) (ok bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[txID]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	c.lru.MoveToFront(elem)
+	entry := *elem.Value.(*memoryTxCacheEntry)
+	c.mu.Unlock()
+
+	if isDetailedTx(tx) && !entry.isDetailed {
+		return false
+	}
+
+	return json.Unmarshal(entry.data, tx) == nil
+}
+
+func (c *MemoryTxCache) Delete(txID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[txID]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, txID)
+	}
+	return nil
+}
+
+func (c *MemoryTxCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	return nil
+}
+
+// NoopTxCache disables transaction caching.
+type NoopTxCache struct{}
+
+func (NoopTxCache) Store(string, any) error {
+	return nil
+}
+
+func (NoopTxCache) Load(string, any) bool {
+	return false
+}
+
+func (NoopTxCache) Delete(string) error {
+	return nil
+}
+
+func (NoopTxCache) Close() error {
+	return nil
+}
+
+// txCacheKey namespaces a transaction id by the client network.
+func (s *Client) txCacheKey(txID string) string {
+	return s.Network().GenesisHash.String() + ":" + txID
+}
+
+// cachedTxResult returns the transaction part of a cached value.
+func cachedTxResult(tx any) *GetTransactionResult {
+	switch tx := tx.(type) {
+	case *GetTransactionResult:
+		return tx
+	case *DetailedTransaction:
+		return tx.GetTransactionResult
+	}
+	return nil
+}
+
+// storeCachedTx caches a confirmed transaction, or detailed transaction, with
+// the height of its block. Unconfirmed transactions are not cached.
+func (s *Client) storeCachedTx(ctx context.Context, txID string, tx any) {
+	res := cachedTxResult(tx)
+	if res == nil || res.Confirmations <= 0 || res.Blockhash == "" {
+		return
+	}
+
+	if res.BlockHeight <= 0 {
+		tip, err := s.chainTip(ctx)
+		if err != nil {
+			return
+		}
+		res.BlockHeight = tip - int64(res.Confirmations) + 1
+	}
+
+	err := s.txCache.Store(s.txCacheKey(txID), tx)
+	if err != nil {
+		s.logger.Errorf("Store tx %s in cache failed: %v", txID, err)
+	}
+}
+
+// loadCachedTx loads a cached transaction, or detailed transaction, and
+// recomputes its confirmations against the chain tip. Entries whose block
+// is no longer in the chain are evicted.
+func (s *Client) loadCachedTx(ctx context.Context, txID string, tx any) bool {
+	key := s.txCacheKey(txID)
+	if !s.txCache.Load(key, tx) {
+		return false
+	}
+
+	res := cachedTxResult(tx)
+	if res == nil || res.BlockHeight <= 0 || res.Blockhash == "" {
+		_ = s.txCache.Delete(key)
+		return false
+	}
+
+	tip, err := s.chainTip(ctx)
+	if err != nil || res.BlockHeight > tip {
+		return false
+	}
+
+	// Blocks deep enough are not checked for reorganizations.
+	depth := tip - res.BlockHeight + 1
+	if depth <= maxReorgDepth {
+		header, err := s.blockHeader(ctx, res.BlockHeight)
+		if err != nil {
+			return false
+		}
+
+		if header.BlockHash().String() != res.Blockhash {
+			s.logger.Debugf(
+				"Tx %s block %s reorged out, evicting from cache",
+				txID,
+				res.Blockhash,
+			)
+			_ = s.txCache.Delete(key)
+			return false
+		}
+	}
+
+	res.Confirmations = int32(depth)
+	return true
+}
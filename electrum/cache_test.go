@@ -0,0 +1,133 @@
+package electrum
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTxCacheStore(t *testing.T, store TxCacheStore) {
+	tx := GetTransactionResult{TxID: "a", Confirmations: 7}
+	require.NoError(t, store.Store("a", tx))
+
+	var loaded GetTransactionResult
+	require.True(t, store.Load("a", &loaded))
+	assert.Equal(t, tx, loaded)
+
+	// A plain transaction does not load as a detailed one.
+	var detailed DetailedTransaction
+	assert.False(t, store.Load("a", &detailed))
+
+	require.NoError(t, store.Store("a", DetailedTransaction{
+		GetTransactionResult: &tx,
+		FeeInSat:             150,
+	}))
+	require.True(t, store.Load("a", &detailed))
+	assert.Equal(t, int64(150), int64(detailed.FeeInSat))
+
+	// A detailed transaction is not overwritten by a plain one.
+	require.NoError(t, store.Store("a", GetTransactionResult{TxID: "b"}))
+	require.True(t, store.Load("a", &detailed))
+	assert.Equal(t, "a", detailed.TxID)
+
+	assert.False(t, store.Load("missing", &loaded))
+}
+
+func TestMemoryTxCache(t *testing.T) {
+	testTxCacheStore(t, NewMemoryTxCache(10))
+
+	cache := NewMemoryTxCache(2)
+	require.NoError(t, cache.Store("a", GetTransactionResult{TxID: "a"}))
+	require.NoError(t, cache.Store("b", GetTransactionResult{TxID: "b"}))
+
+	var tx GetTransactionResult
+	require.True(t, cache.Load("a", &tx))
+	require.NoError(t, cache.Store("c", GetTransactionResult{TxID: "c"}))
+
+	assert.True(t, cache.Load("a", &tx))
+	assert.False(t, cache.Load("b", &tx))
+	assert.True(t, cache.Load("c", &tx))
+}
+
+func TestSQLiteTxCache(t *testing.T) {
+	cache, err := NewSQLiteTxCache(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	testTxCacheStore(t, cache)
+}
+
+func TestNoopTxCache(t *testing.T) {
+	var cache NoopTxCache
+	require.NoError(t, cache.Store("a", GetTransactionResult{TxID: "a"}))
+
+	var tx GetTransactionResult
+	assert.False(t, cache.Load("a", &tx))
+}
+
+func TestClientTxCache(t *testing.T) {
+	headers, err := ParseBlockHeaders(testHeader1 + testHeader2)
+	require.NoError(t, err)
+
+	chain := NewHeaderChain(&chaincfg.MainNetParams, NewMemoryHeaderStore())
+	genesis := chaincfg.MainNetParams.GenesisBlock.Header
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{&genesis}))
+	require.NoError(t, chain.Connect(headers))
+
+	store := NewMemoryTxCache(10)
+	client := &Client{
+		txCache:     store,
+		headerChain: chain,
+		logger:      newLogger(),
+	}
+	client.setTip(2)
+	ctx := context.Background()
+
+	client.storeCachedTx(ctx, "a", &GetTransactionResult{
+		TxID:          "a",
+		Blockhash:     headers[0].BlockHash().String(),
+		Confirmations: 2,
+	})
+
+	var tx GetTransactionResult
+	require.True(t, client.loadCachedTx(ctx, "a", &tx))
+	assert.Equal(t, int64(1), tx.BlockHeight)
+	assert.Equal(t, int32(2), tx.Confirmations)
+
+	// Confirmations follow the tip.
+	client.setTip(3)
+	tx = GetTransactionResult{}
+	require.True(t, client.loadCachedTx(ctx, "a", &tx))
+	assert.Equal(t, int32(3), tx.Confirmations)
+
+	// Unconfirmed transactions are not cached.
+	client.storeCachedTx(ctx, "b", &GetTransactionResult{TxID: "b"})
+	assert.False(t, client.loadCachedTx(ctx, "b", &tx))
+
+	// Transactions of reorged out blocks are evicted.
+	client.setTip(2)
+	client.storeCachedTx(ctx, "c", &GetTransactionResult{
+		TxID:          "c",
+		Blockhash:     genesis.BlockHash().String(),
+		BlockHeight:   2,
+		Confirmations: 1,
+	})
+	assert.True(t, store.Load(client.txCacheKey("c"), &tx))
+	assert.False(t, client.loadCachedTx(ctx, "c", &tx))
+	assert.False(t, store.Load(client.txCacheKey("c"), &tx))
+
+	// Entries are namespaced by network.
+	testnet := &Client{
+		txCache:     store,
+		headerChain: chain,
+		params:      &chaincfg.TestNet3Params,
+		logger:      newLogger(),
+	}
+	testnet.setTip(2)
+	assert.False(t, testnet.loadCachedTx(ctx, "a", &tx))
+}
@@ -0,0 +1,292 @@
+package electrum
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrCertificateChanged throws an error if a server presents another
+	// certificate than the one recorded in the certificate store.
+	ErrCertificateChanged = errors.New("server certificate has changed")
+
+	// ErrCertificateNotPinned throws an error if a server presents a
+	// certificate matching none of the pinned fingerprints.
+	ErrCertificateNotPinned = errors.New("server certificate is not pinned")
+
+	// ErrNoCertificate throws an error if a server presents no certificate.
+	ErrNoCertificate = errors.New("server presented no certificate")
+
+	// ErrInvalidCertHost throws an error if a host cannot name a file of the
+	// certificate store.
+	ErrInvalidCertHost = errors.New("invalid certificate store host")
+)
+
+// CertFingerprint returns the hex SHA-256 fingerprint of a certificate.
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint accepts fingerprints in upper case or with colons.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
+
+// CertStore trusts the certificate of each server on first use, like the
+// certs directory of Electrum: the certificate is saved as PEM in a file
+// named after the server host, then later connections must present it.
+type CertStore struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// NewCertStore opens or creates a certificate store in dir.
+func NewCertStore(dir string) (*CertStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertStore{dir: dir}, nil
+}
+
+// path returns the file of host. Hosts naming the store directory or its
+// parent, such as the empty host of an address like ":50002", are rejected.
+func (cs *CertStore) path(host string) (string, error) {
+	if host == "" || host == "." || host == ".." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCertHost, host)
+	}
+
+	return filepath.Join(cs.dir, strings.NewReplacer(
+		"/", "_",
+		"\\", "_",
+	).Replace(host)), nil
+}
+
+func (cs *CertStore) load(host string) (*x509.Certificate, error) {
+	path, err := cs.path(host)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("invalid certificate file for %s", host)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Fingerprint returns the fingerprint of the certificate recorded for host.
+func (cs *CertStore) Fingerprint(host string) (string, bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	cert, err := cs.load(host)
+	if err != nil {
+		return "", false
+	}
+
+	return CertFingerprint(cert), true
+}
+
+// Forget removes the certificate recorded for host, to accept a renewed
+// certificate on the next connection.
+func (cs *CertStore) Forget(host string) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	path, err := cs.path(host)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Verify records cert for host if it has none, else checks cert is the
+// recorded one.
+func (cs *CertStore) Verify(host string, cert *x509.Certificate) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	path, err := cs.path(host)
+	if err != nil {
+		return err
+	}
+
+	known, err := cs.load(host)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.WriteFile(
+			path,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+			0o600,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	if CertFingerprint(known) != CertFingerprint(cert) {
+		return fmt.Errorf(
+			"%w: %s presented %s, expected %s",
+			ErrCertificateChanged,
+			host,
+			CertFingerprint(cert),
+			CertFingerprint(known),
+		)
+	}
+
+	return nil
+}
+
+// WithCertStore trusts SSL and WebSocket servers whose certificate is not
+// signed by a known certificate authority, e.g. self-signed, on first use,
+// then checks they present the same certificate. Certificates signed by a
+// known authority are verified as usual.
+func WithCertStore(store *CertStore) ClientOption {
+	return func(c *Client) {
+		c.certStore = store
+	}
+}
+
+// WithPinnedCertificates only accepts SSL and WebSocket servers presenting
+// a certificate with one of the hex SHA-256 fingerprints. Pinned
+// certificates need not be signed by a known certificate authority.
+func WithPinnedCertificates(fingerprints ...string) ClientOption {
+	return func(c *Client) {
+		for _, fingerprint := range fingerprints {
+			c.certPins = append(c.certPins, normalizeFingerprint(fingerprint))
+		}
+	}
+}
+
+// tlsConfig returns config verifying the certificate of host with the pins
+// and certificate store of the client, if any. Certificates are verified
+// against the roots of config first, unless it skips verification, and
+// only checked against the certificate store when their authority is
+// unknown. The VerifyConnection of config, if any, is still called.
+func (s *Client) tlsConfig(config *tls.Config, host string) *tls.Config {
+	if s.certStore == nil && len(s.certPins) == 0 {
+		return config
+	}
+
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+
+	// Certificates are verified by VerifyConnection instead, which tells
+	// unknown authorities from other failures.
+	skipVerify := config.InsecureSkipVerify
+	verifyConnection := config.VerifyConnection
+	config.InsecureSkipVerify = true
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return ErrNoCertificate
+		}
+		cert := state.PeerCertificates[0]
+
+		trusted := false
+		if !skipVerify {
+			err := verifyChain(config, state, host)
+			var unknownAuthority x509.UnknownAuthorityError
+			if err != nil && !errors.As(err, &unknownAuthority) {
+				return err
+			}
+			trusted = err == nil
+		}
+
+		if len(s.certPins) > 0 {
+			err := s.verifyPin(cert, host)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !trusted && s.certStore != nil {
+			err := s.certStore.Verify(host, cert)
+			if err != nil {
+				return err
+			}
+		}
+
+		if verifyConnection != nil {
+			return verifyConnection(state)
+		}
+
+		return nil
+	}
+
+	return config
+}
+
+// verifyChain verifies the certificates of a connection against the roots
+// of config, and the leaf against the server name, as crypto/tls does.
+func verifyChain(
+	config *tls.Config,
+	state tls.ConnectionState,
+	host string,
+) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         config.RootCAs,
+		Intermediates: intermediates,
+	}
+	if config.Time != nil {
+		opts.CurrentTime = config.Time()
+	}
+
+	cert := state.PeerCertificates[0]
+	_, err := cert.Verify(opts)
+	if err != nil {
+		return err
+	}
+
+	name := state.ServerName
+	if name == "" {
+		name = host
+	}
+	return cert.VerifyHostname(name)
+}
+
+// verifyPin checks cert has one of the pinned fingerprints.
+func (s *Client) verifyPin(cert *x509.Certificate, host string) error {
+	fingerprint := CertFingerprint(cert)
+	for _, pin := range s.certPins {
+		if pin == fingerprint {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"%w: %s presented %s",
+		ErrCertificateNotPinned,
+		host,
+		fingerprint,
+	)
+}
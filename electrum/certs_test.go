@@ -0,0 +1,246 @@
+package electrum
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCert creates a certificate from template with a new key, signed
+// by parent or self-signed without one. It is valid for an hour unless the
+// template sets its validity.
+func newTestCert(
+	t *testing.T,
+	template *x509.Certificate,
+	parent *tls.Certificate,
+) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(1)
+	if template.NotAfter.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader,
+		template,
+		signer,
+		&key.PublicKey,
+		signerKey,
+	)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+}
+
+// serveTLS accepts TLS connections presenting cert, and returns the
+// listener address.
+func serveTLS(t *testing.T, cert tls.Certificate) string {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// serveSelfSigned accepts TLS connections with a new self-signed
+// certificate, and returns the listener address and certificate.
+func serveSelfSigned(t *testing.T) (string, *x509.Certificate) {
+	cert := newTestCert(
+		t,
+		&x509.Certificate{Subject: pkix.Name{CommonName: "electrum"}},
+		nil,
+	)
+	return serveTLS(t, cert), cert.Leaf
+}
+
+func TestCertStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewCertStore(t.TempDir())
+	require.NoError(t, err)
+
+	addr, cert := serveSelfSigned(t)
+	host, _, _ := net.SplitHostPort(addr)
+
+	// Trusted on first use, then verified.
+	for i := 0; i < 2; i++ {
+		client, err := NewClientSSL(ctx, addr, nil, WithCertStore(store))
+		require.NoError(t, err)
+		client.Shutdown()
+	}
+
+	fingerprint, ok := store.Fingerprint(host)
+	require.True(t, ok)
+	assert.Equal(t, CertFingerprint(cert), fingerprint)
+
+	// Same host, another certificate.
+	otherAddr, _ := serveSelfSigned(t)
+	_, err = NewClientSSL(ctx, otherAddr, nil, WithCertStore(store))
+	assert.ErrorIs(t, err, ErrCertificateChanged)
+
+	require.NoError(t, store.Forget(host))
+	client, err := NewClientSSL(ctx, otherAddr, nil, WithCertStore(store))
+	require.NoError(t, err)
+	client.Shutdown()
+}
+
+func TestCertStoreCASigned(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewCertStore(t.TempDir())
+	require.NoError(t, err)
+
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	var verified int32
+	config := &tls.Config{
+		RootCAs: roots,
+		VerifyConnection: func(tls.ConnectionState) error {
+			atomic.AddInt32(&verified, 1)
+			return nil
+		},
+	}
+	localhost := []net.IP{net.IPv4(127, 0, 0, 1)}
+
+	// Signed for the server address, it is trusted without being recorded,
+	// and the VerifyConnection of the config still runs.
+	addr := serveTLS(t, newTestCert(
+		t,
+		&x509.Certificate{IPAddresses: localhost},
+		&ca,
+	))
+	client, err := NewClientSSL(ctx, addr, config, WithCertStore(store))
+	require.NoError(t, err)
+	client.Shutdown()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&verified))
+
+	_, ok := store.Fingerprint("127.0.0.1")
+	assert.False(t, ok)
+
+	// Signed for another host or expired, it is rejected instead of trusted
+	// on first use.
+	var hostnameErr x509.HostnameError
+	addr = serveTLS(t, newTestCert(
+		t,
+		&x509.Certificate{DNSNames: []string{"electrum.example"}},
+		&ca,
+	))
+	_, err = NewClientSSL(ctx, addr, config, WithCertStore(store))
+	assert.ErrorAs(t, err, &hostnameErr)
+
+	var invalidErr x509.CertificateInvalidError
+	addr = serveTLS(t, newTestCert(
+		t,
+		&x509.Certificate{
+			IPAddresses: localhost,
+			NotBefore:   time.Now().Add(-2 * time.Hour),
+			NotAfter:    time.Now().Add(-time.Hour),
+		},
+		&ca,
+	))
+	_, err = NewClientSSL(ctx, addr, config, WithCertStore(store))
+	assert.ErrorAs(t, err, &invalidErr)
+
+	_, ok = store.Fingerprint("127.0.0.1")
+	assert.False(t, ok)
+}
+
+func TestCertStoreInvalidHost(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+	store, err := NewCertStore(dir)
+	require.NoError(t, err)
+
+	_, cert := serveSelfSigned(t)
+	for _, host := range []string{"", ".", ".."} {
+		assert.ErrorIs(t, store.Verify(host, cert), ErrInvalidCertHost, host)
+		assert.ErrorIs(t, store.Forget(host), ErrInvalidCertHost, host)
+
+		_, ok := store.Fingerprint(host)
+		assert.False(t, ok, host)
+	}
+
+	// Nothing was written over the store directory or next to it.
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestPinnedCertificates(t *testing.T) {
+	ctx := context.Background()
+	addr, cert := serveSelfSigned(t)
+
+	_, err := NewClientSSL(
+		ctx,
+		addr,
+		nil,
+		WithPinnedCertificates(strings.Repeat("00", 32)),
+	)
+	assert.ErrorIs(t, err, ErrCertificateNotPinned)
+
+	// Fingerprints are accepted in the colon separated upper case format.
+	fingerprint := CertFingerprint(cert)
+	var parts []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		parts = append(parts, strings.ToUpper(fingerprint[i:i+2]))
+	}
+
+	client, err := NewClientSSL(
+		ctx,
+		addr,
+		nil,
+		WithPinnedCertificates(strings.Join(parts, ":")),
+	)
+	require.NoError(t, err)
+	client.Shutdown()
+}
@@ -0,0 +1,88 @@
+package electrum
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrCheckpointMismatch throws an error if headers returned with a checkpoint
+	// do not lead to the checkpoint root, or the root is not the known one.
+	ErrCheckpointMismatch = errors.New("headers do not match checkpoint")
+)
+
+// WithCheckpoints sets the known merkle roots of all block hashes up to each
+// checkpoint height. Headers requested with one of these checkpoint heights
+// must lead to the known root. At other checkpoint heights, headers are only
+// checked against the root returned by the server, which proves the server
+// is consistent with itself, not that it is on the right chain.
+func WithCheckpoints(checkpoints map[uint64]string) ClientOption {
+	return func(c *Client) {
+		c.checkpoints = checkpoints
+	}
+}
+
+// VerifyCheckpointBranch checks that the header at height is included in the
+// merkle tree of all block hashes up to the checkpoint, whose root is root.
+// https://electrumx.readthedocs.io/en/latest/protocol-basics.html#block-headers
+func VerifyCheckpointBranch(
+	headerHex string,
+	height uint64,
+	branch []string,
+	root string,
+) error {
+	header, err := ParseBlockHeader(headerHex)
+	if err != nil {
+		return err
+	}
+
+	computed, err := ComputeMerkleRoot(
+		header.BlockHash().String(),
+		branch,
+		uint32(height),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCheckpointMismatch, err)
+	}
+
+	if !strings.EqualFold(computed.String(), root) {
+		return fmt.Errorf(
+			"%w: branch of header %d leads to %s, not %s",
+			ErrCheckpointMismatch,
+			height,
+			computed,
+			root,
+		)
+	}
+
+	return nil
+}
+
+// verifyCheckpoint checks a header branch against the returned root and the
+// known root of the checkpoint, if any. Without a known root, a server
+// forging a branch and root for its header passes.
+func (s *Client) verifyCheckpoint(
+	headerHex string,
+	height, checkpointHeight uint64,
+	branch []string,
+	root string,
+) error {
+	err := VerifyCheckpointBranch(headerHex, height, branch, root)
+	if err != nil {
+		return err
+	}
+
+	known, ok := s.checkpoints[checkpointHeight]
+	if ok && !strings.EqualFold(known, root) {
+		return fmt.Errorf(
+			"%w: root of checkpoint %d is %s, not %s",
+			ErrCheckpointMismatch,
+			checkpointHeight,
+			root,
+			known,
+		)
+	}
+
+	return nil
+}
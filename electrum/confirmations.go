@@ -0,0 +1,506 @@
+package electrum
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var (
+	// ErrNoWatchableOutput throws an error if a transaction has no spendable
+	// output whose scripthash can be subscribed to.
+	ErrNoWatchableOutput = errors.New("transaction has no spendable output")
+
+	// ErrInvalidConfirmations throws an error if the number of confirmations
+	// to wait for is not positive.
+	ErrInvalidConfirmations = errors.New("confirmations must be positive")
+)
+
+// TxEvent is emitted while waiting for the confirmations of a transaction,
+// either a *TxSeen, *TxConfirmed, *TxReorged or *TxDropped.
+type TxEvent interface {
+	isTxEvent()
+}
+
+// TxSeen reports the transaction in the mempool.
+type TxSeen struct {
+	TxID string
+}
+
+// TxConfirmed reports the transaction mined at Height, first with 1
+// confirmation then each time the number of confirmations grows.
+type TxConfirmed struct {
+	TxID          string
+	Height        int64
+	Confirmations int64
+}
+
+// TxReorged reports the block at Height holding the transaction was
+// disconnected from the chain, even if the transaction was mined again at
+// the same height.
+type TxReorged struct {
+	TxID   string
+	Height int64
+}
+
+// TxDropped reports the transaction left the mempool without being mined,
+// e.g. replaced by a conflicting transaction.
+type TxDropped struct {
+	TxID string
+}
+
+func (*TxSeen) isTxEvent() {}
+
+func (*TxConfirmed) isTxEvent() {}
+
+func (*TxReorged) isTxEvent() {}
+
+func (*TxDropped) isTxEvent() {}
+
+// ConfirmationWatcher follows many transactions until they reach their
+// number of confirmations, with a single header subscription and a single
+// scripthash subscription on their outputs.
+type ConfirmationWatcher struct {
+	client  *Client
+	sub     *ScripthashSubscription
+	tip     int64
+	tipHash chainhash.Hash
+	watches map[string][]*txWatch // by scripthash
+
+	lock sync.Mutex
+}
+
+// Updates requested to the worker of a watch, besides counting the
+// confirmations against the tip.
+const (
+	// refreshHistory reads the history of the scripthash again.
+	refreshHistory uint32 = 1 << iota
+	// checkBlock checks the block holding the transaction is still in the
+	// chain.
+	checkBlock
+)
+
+// txWatch is the state of a watched transaction.
+type txWatch struct {
+	ctx           context.Context
+	txID          string
+	scripthash    string
+	confirmations int64
+	events        chan TxEvent
+	stop          chan struct{}
+
+	// pending holds the updates requested since the worker last woke up.
+	pending uint32
+	wake    chan struct{}
+
+	seen          bool
+	height        int64
+	blockHash     chainhash.Hash
+	lastConfirmed int64
+	done          bool
+
+	lock sync.Mutex
+}
+
+// NewConfirmationWatcher subscribes to headers and creates a watcher. It
+// runs until the client is shut down.
+func (s *Client) NewConfirmationWatcher(
+	ctx context.Context,
+) (*ConfirmationWatcher, error) {
+	headers, err := s.SubscribeHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ConfirmationWatcher{
+		client:  s,
+		watches: make(map[string][]*txWatch),
+	}
+
+	first := <-headers
+	if first != nil {
+		w.tip = first.Height
+		w.tipHash = headerHash(first.Hex)
+	}
+
+	sub, notifs := s.SubscribeScripthash()
+	w.sub = sub
+
+	go w.run(headers, notifs)
+
+	return w, nil
+}
+
+// WaitForConfirmations watches a transaction until it has confirmations
+// confirmations, using a watcher shared by every call on the client.
+func (s *Client) WaitForConfirmations(
+	ctx context.Context,
+	txID string,
+	confirmations int,
+) (<-chan TxEvent, error) {
+	s.confirmationsLock.Lock()
+	if s.confirmations == nil {
+		w, err := s.NewConfirmationWatcher(ctx)
+		if err != nil {
+			s.confirmationsLock.Unlock()
+			return nil, err
+		}
+		s.confirmations = w
+	}
+	w := s.confirmations
+	s.confirmationsLock.Unlock()
+
+	return w.WaitForConfirmations(ctx, txID, confirmations)
+}
+
+// WaitForConfirmations watches a transaction and emits its progress on the
+// returned channel, closed once the transaction has confirmations
+// confirmations or ctx is done.
+func (w *ConfirmationWatcher) WaitForConfirmations(
+	ctx context.Context,
+	txID string,
+	confirmations int,
+) (<-chan TxEvent, error) {
+	if confirmations <= 0 {
+		return nil, ErrInvalidConfirmations
+	}
+
+	// Only the outputs are needed, not the block fields.
+	tx, err := w.client.getTransaction(ctx, txID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	scripthash, err := watchableScripthash(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	watch := &txWatch{
+		ctx:           ctx,
+		txID:          txID,
+		scripthash:    scripthash,
+		confirmations: int64(confirmations),
+		events:        make(chan TxEvent, 16),
+		stop:          make(chan struct{}),
+		wake:          make(chan struct{}, 1),
+	}
+
+	w.lock.Lock()
+	subscribe := len(w.watches[scripthash]) == 0
+	w.watches[scripthash] = append(w.watches[scripthash], watch)
+	w.lock.Unlock()
+
+	if subscribe {
+		err = w.sub.Add(ctx, scripthash)
+		if err != nil {
+			w.remove(watch)
+			return nil, err
+		}
+	}
+
+	watch.signal(refreshHistory)
+	go w.work(watch)
+
+	return watch.events, nil
+}
+
+// signal requests updates of a watch, merged with those still pending.
+func (watch *txWatch) signal(updates uint32) {
+	for {
+		pending := atomic.LoadUint32(&watch.pending)
+		if atomic.CompareAndSwapUint32(
+			&watch.pending,
+			pending,
+			pending|updates,
+		) {
+			break
+		}
+	}
+
+	select {
+	case watch.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs the updates of a watch one at a time, until it is done.
+func (w *ConfirmationWatcher) work(watch *txWatch) {
+	for {
+		select {
+		case <-watch.wake:
+			w.update(watch, atomic.SwapUint32(&watch.pending, 0))
+
+		case <-watch.ctx.Done():
+			watch.lock.Lock()
+			w.finish(watch)
+			watch.lock.Unlock()
+			return
+
+		case <-watch.stop:
+			return
+
+		case <-w.client.quit:
+			return
+		}
+	}
+}
+
+// watchableScripthash returns the scripthash of the first spendable output,
+// whose history holds the transaction.
+func watchableScripthash(tx *GetTransactionResult) (string, error) {
+	for _, vout := range tx.Vout {
+		script, err := hex.DecodeString(vout.ScriptPubKey.Hex)
+		if err != nil || len(script) == 0 || txscript.IsUnspendable(script) {
+			continue
+		}
+		return ScriptToElectrumScriptHash(script), nil
+	}
+
+	return "", ErrNoWatchableOutput
+}
+
+func (w *ConfirmationWatcher) run(
+	headers <-chan *SubscribeHeadersResult,
+	notifs <-chan *SubscribeNotif,
+) {
+	for {
+		select {
+		case <-w.client.quit:
+			return
+
+		case header := <-headers:
+			if header == nil {
+				continue
+			}
+
+			parsed, err := ParseBlockHeader(header.Hex)
+			var hash chainhash.Hash
+			if err == nil {
+				hash = parsed.BlockHash()
+			}
+
+			w.lock.Lock()
+			// The same tip is notified again after a reconnection.
+			if err == nil && hash == w.tipHash {
+				w.lock.Unlock()
+				continue
+			}
+			// A tip not extending the previous one may have disconnected
+			// the blocks of the watched transactions.
+			var updates uint32
+			if err != nil || parsed.PrevBlock != w.tipHash {
+				updates = checkBlock
+			}
+			w.tip = header.Height
+			w.tipHash = hash
+			var watches []*txWatch
+			for _, list := range w.watches {
+				watches = append(watches, list...)
+			}
+			w.lock.Unlock()
+
+			for _, watch := range watches {
+				watch.signal(updates)
+			}
+
+		case notif := <-notifs:
+			w.lock.Lock()
+			watches := append([]*txWatch(nil), w.watches[notif.Params[0]]...)
+			w.lock.Unlock()
+
+			for _, watch := range watches {
+				watch.signal(refreshHistory)
+			}
+		}
+	}
+}
+
+// headerHash returns the hash of a hex header, zero if it is invalid.
+func headerHash(headerHex string) chainhash.Hash {
+	header, err := ParseBlockHeader(headerHex)
+	if err != nil {
+		return chainhash.Hash{}
+	}
+	return header.BlockHash()
+}
+
+// blockHash returns the hash of the block at height in the chain of the
+// remote server.
+func (w *ConfirmationWatcher) blockHash(
+	ctx context.Context,
+	height int64,
+) (chainhash.Hash, error) {
+	res, err := w.client.GetBlockHeader(ctx, uint64(height))
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+
+	header, err := ParseBlockHeader(res.Header)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+
+	return header.BlockHash(), nil
+}
+
+// update refreshes the state of a watch as requested by updates, then
+// reports its confirmations against the tip.
+func (w *ConfirmationWatcher) update(watch *txWatch, updates uint32) {
+	watch.lock.Lock()
+	defer watch.lock.Unlock()
+
+	if watch.done {
+		return
+	}
+
+	w.lock.Lock()
+	tip := w.tip
+	w.lock.Unlock()
+
+	// The block of the transaction is above a shorter new chain.
+	if watch.height > tip {
+		updates |= refreshHistory
+	}
+
+	disconnected := false
+	if updates&checkBlock != 0 && watch.height > 0 {
+		hash, err := w.blockHash(watch.ctx, watch.height)
+		if err != nil {
+			w.client.logger.Errorf(
+				"Get block of tx %s failed: %v",
+				watch.txID,
+				err,
+			)
+			return
+		}
+		if hash != watch.blockHash {
+			disconnected = true
+			updates |= refreshHistory
+		}
+	}
+
+	if updates&refreshHistory != 0 {
+		history, err := w.client.GetHistory(watch.ctx, watch.scripthash)
+		if err != nil {
+			w.client.logger.Errorf(
+				"Get history of tx %s failed: %v",
+				watch.txID,
+				err,
+			)
+			return
+		}
+
+		found, height := false, int64(0)
+		for _, h := range history {
+			if h.Hash == watch.txID {
+				found, height = true, h.Height
+				break
+			}
+		}
+
+		// Mempool transactions have a height of 0, or -1 with unconfirmed
+		// inputs.
+		if height < 0 {
+			height = 0
+		}
+
+		reorged := watch.height > 0 && (disconnected || height != watch.height)
+		if reorged {
+			w.emit(watch, &TxReorged{TxID: watch.txID, Height: watch.height})
+			watch.height = 0
+			watch.lastConfirmed = 0
+		}
+
+		switch {
+		case found && height > 0 && height != watch.height:
+			hash, err := w.blockHash(watch.ctx, height)
+			if err != nil {
+				w.client.logger.Errorf(
+					"Get block of tx %s failed: %v",
+					watch.txID,
+					err,
+				)
+				return
+			}
+			watch.height = height
+			watch.blockHash = hash
+		case found && height == 0 && (!watch.seen || reorged):
+			watch.seen = true
+			w.emit(watch, &TxSeen{TxID: watch.txID})
+		case !found && watch.seen:
+			watch.seen = false
+			w.emit(watch, &TxDropped{TxID: watch.txID})
+		}
+	}
+
+	if watch.height <= 0 {
+		return
+	}
+
+	confirmations := tip - watch.height + 1
+	if confirmations <= watch.lastConfirmed {
+		return
+	}
+
+	watch.seen = true
+	watch.lastConfirmed = confirmations
+	w.emit(watch, &TxConfirmed{
+		TxID:          watch.txID,
+		Height:        watch.height,
+		Confirmations: confirmations,
+	})
+
+	if confirmations >= watch.confirmations {
+		w.finish(watch)
+	}
+}
+
+func (w *ConfirmationWatcher) emit(watch *txWatch, event TxEvent) {
+	select {
+	case watch.events <- event:
+	case <-watch.ctx.Done():
+	}
+}
+
+// finish closes the events of a watch and stops watching it. The watch lock
+// must be held.
+func (w *ConfirmationWatcher) finish(watch *txWatch) {
+	if watch.done {
+		return
+	}
+
+	watch.done = true
+	close(watch.events)
+	close(watch.stop)
+	w.remove(watch)
+}
+
+func (w *ConfirmationWatcher) remove(watch *txWatch) {
+	w.lock.Lock()
+	watches := w.watches[watch.scripthash]
+	for i, other := range watches {
+		if other == watch {
+			watches = append(watches[:i:i], watches[i+1:]...)
+			break
+		}
+	}
+
+	if len(watches) > 0 {
+		w.watches[watch.scripthash] = watches
+		w.lock.Unlock()
+		return
+	}
+
+	delete(w.watches, watch.scripthash)
+	w.lock.Unlock()
+
+	// Not under the watcher lock, the subscription may be blocked
+	// delivering a notification to run.
+	_ = w.sub.Remove(watch.scripthash)
+}
@@ -0,0 +1,218 @@
+package electrum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextTxEvent(t *testing.T, events <-chan TxEvent) TxEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no tx event")
+		return nil
+	}
+}
+
+func assertTxEventsClosed(t *testing.T, events <-chan TxEvent) {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		assert.False(t, ok, "unexpected event %v", event)
+	case <-time.After(time.Second):
+		t.Fatal("events not closed")
+	}
+}
+
+func newWatchedTx(id byte) *wire.MsgTx {
+	return newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{id}}},
+		testScript(id),
+	)
+}
+
+func TestWaitForConfirmations(t *testing.T) {
+	server := newTxServer(100)
+	msgTx := newWatchedTx(1)
+	script := msgTx.TxOut[0].PkScript
+	txID := server.add(msgTx, 0)
+
+	client := newFakeClient(t, server.fakeServer, WithRawTransactions())
+	events, err := client.WaitForConfirmations(context.Background(), txID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, &TxSeen{TxID: txID}, nextTxEvent(t, events))
+
+	server.add(msgTx, 101)
+	server.mine(101)
+	server.notify(script)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID, Height: 101, Confirmations: 1},
+		nextTxEvent(t, events),
+	)
+
+	// The block is disconnected and the transaction back in the mempool.
+	server.add(msgTx, 0)
+	server.notify(script)
+	assert.Equal(
+		t,
+		&TxReorged{TxID: txID, Height: 101},
+		nextTxEvent(t, events),
+	)
+	assert.Equal(t, &TxSeen{TxID: txID}, nextTxEvent(t, events))
+
+	server.add(msgTx, 102)
+	server.mine(102)
+	server.notify(script)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID, Height: 102, Confirmations: 1},
+		nextTxEvent(t, events),
+	)
+
+	for confirmations := int64(2); confirmations <= 3; confirmations++ {
+		server.mine(101 + confirmations)
+		assert.Equal(
+			t,
+			&TxConfirmed{
+				TxID:          txID,
+				Height:        102,
+				Confirmations: confirmations,
+			},
+			nextTxEvent(t, events),
+		)
+	}
+	assertTxEventsClosed(t, events)
+}
+
+func TestWaitForConfirmationsHeaderReorg(t *testing.T) {
+	server := newTxServer(101)
+	msgTx := newWatchedTx(1)
+	txID := server.add(msgTx, 101)
+
+	client := newFakeClient(t, server.fakeServer, WithRawTransactions())
+	events, err := client.WaitForConfirmations(context.Background(), txID, 3)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID, Height: 101, Confirmations: 1},
+		nextTxEvent(t, events),
+	)
+
+	// The transaction is mined again at the same height in another block,
+	// which leaves the status of its scripthash unchanged.
+	server.reorg()
+	server.mine(101)
+	assert.Equal(
+		t,
+		&TxReorged{TxID: txID, Height: 101},
+		nextTxEvent(t, events),
+	)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID, Height: 101, Confirmations: 1},
+		nextTxEvent(t, events),
+	)
+
+	// Then at another height, with no scripthash notification.
+	server.add(msgTx, 102)
+	server.mine(102)
+	assert.Equal(
+		t,
+		&TxReorged{TxID: txID, Height: 101},
+		nextTxEvent(t, events),
+	)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID, Height: 102, Confirmations: 1},
+		nextTxEvent(t, events),
+	)
+}
+
+func TestWaitForConfirmationsDropped(t *testing.T) {
+	server := newTxServer(100)
+	msgTx := newWatchedTx(1)
+	txID := server.add(msgTx, 0)
+
+	client := newFakeClient(t, server.fakeServer, WithRawTransactions())
+	events, err := client.WaitForConfirmations(context.Background(), txID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &TxSeen{TxID: txID}, nextTxEvent(t, events))
+
+	server.remove(txID)
+	server.notify(msgTx.TxOut[0].PkScript)
+	assert.Equal(t, &TxDropped{TxID: txID}, nextTxEvent(t, events))
+}
+
+func TestWaitForConfirmationsSharedScripthash(t *testing.T) {
+	server := newTxServer(100)
+
+	// Both transactions pay the same script.
+	tx1 := newWatchedTx(1)
+	tx2 := newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{2}}},
+		tx1.TxOut[0].PkScript,
+	)
+	script := tx1.TxOut[0].PkScript
+	txID1 := server.add(tx1, 0)
+	txID2 := server.add(tx2, 0)
+
+	ctx := context.Background()
+	client := newFakeClient(t, server.fakeServer, WithRawTransactions())
+	events1, err := client.WaitForConfirmations(ctx, txID1, 1)
+	require.NoError(t, err)
+	events2, err := client.WaitForConfirmations(ctx, txID2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &TxSeen{TxID: txID1}, nextTxEvent(t, events1))
+	assert.Equal(t, &TxSeen{TxID: txID2}, nextTxEvent(t, events2))
+	assert.Equal(t, 1, server.calls("blockchain.scripthash.subscribe"))
+
+	server.add(tx1, 101)
+	server.mine(101)
+	server.notify(script)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID1, Height: 101, Confirmations: 1},
+		nextTxEvent(t, events1),
+	)
+	assertTxEventsClosed(t, events1)
+
+	// The scripthash is still watched for the second transaction.
+	server.add(tx2, 102)
+	server.mine(102)
+	server.notify(script)
+	assert.Equal(
+		t,
+		&TxConfirmed{TxID: txID2, Height: 102, Confirmations: 1},
+		nextTxEvent(t, events2),
+	)
+	assertTxEventsClosed(t, events2)
+	assert.Equal(t, 1, server.calls("blockchain.scripthash.subscribe"))
+}
+
+func TestWaitForConfirmationsCancel(t *testing.T) {
+	server := newTxServer(100)
+	txID := server.add(newWatchedTx(1), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newFakeClient(t, server.fakeServer, WithRawTransactions())
+	events, err := client.WaitForConfirmations(ctx, txID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &TxSeen{TxID: txID}, nextTxEvent(t, events))
+
+	cancel()
+	assertTxEventsClosed(t, events)
+
+	_, err = client.WaitForConfirmations(ctx, txID, 0)
+	assert.ErrorIs(t, err, ErrInvalidConfirmations)
+}
@@ -0,0 +1,235 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNoQuorum throws an error if not enough servers agreed on a result.
+	ErrNoQuorum = errors.New("servers did not reach quorum")
+
+	// ErrInvalidQuorum throws an error if the quorum is not between 1 and the number of servers.
+	ErrInvalidQuorum = errors.New(
+		"quorum must be between 1 and the number of servers",
+	)
+)
+
+// ServerResponse holds what a single server answered to a consensus read.
+type ServerResponse struct {
+	Server string
+	Result interface{}
+	Err    error
+}
+
+// DisagreementError is returned when fewer than the quorum of servers returned
+// the same result. It matches ErrNoQuorum with errors.Is.
+type DisagreementError struct {
+	Method    string
+	Quorum    int
+	Responses []ServerResponse
+}
+
+func (e *DisagreementError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %v (need %d):", e.Method, ErrNoQuorum, e.Quorum)
+	for _, r := range e.Responses {
+		if r.Err != nil {
+			fmt.Fprintf(&b, " %s=error(%v)", r.Server, r.Err)
+		} else {
+			fmt.Fprintf(&b, " %s=%s", r.Server, mustMarshalJSON(r.Result))
+		}
+	}
+	return b.String()
+}
+
+func (e *DisagreementError) Is(target error) bool {
+	return target == ErrNoQuorum
+}
+
+// Consensus fans out read requests to several servers and only returns a
+// result when at least Quorum of them agree on it.
+type Consensus struct {
+	clients map[string]*Client
+	quorum  int
+}
+
+// NewConsensus creates a consensus reader over the given clients, keyed by a
+// server name used in disagreement reports.
+func NewConsensus(
+	quorum int,
+	clients map[string]*Client,
+) (*Consensus, error) {
+	if quorum < 1 || quorum > len(clients) {
+		return nil, ErrInvalidQuorum
+	}
+
+	return &Consensus{
+		clients: clients,
+		quorum:  quorum,
+	}, nil
+}
+
+// Consensus creates a consensus reader over the servers of the pool that
+// requests would be sent to, the healthy ones unless all are evicted.
+func (p *Pool) Consensus(quorum int) (*Consensus, error) {
+	clients := make(map[string]*Client)
+	for _, ps := range p.candidates() {
+		ps.lock.Lock()
+		clients[ps.Addr] = ps.client
+		ps.lock.Unlock()
+	}
+
+	return NewConsensus(quorum, clients)
+}
+
+type consensusResponse[T any] struct {
+	server string
+	result T
+	err    error
+}
+
+// consensusCall calls every server and returns the first result that quorum
+// servers agree on, comparing results by the key computed by key.
+func consensusCall[T any](
+	ctx context.Context,
+	c *Consensus,
+	method string,
+	call func(ctx context.Context, client *Client) (T, error),
+	key func(T) string,
+) (T, error) {
+	// The calls still running once the quorum is reached are cancelled and
+	// waited for, so that none outlives consensusCall.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	respChan := make(chan consensusResponse[T], len(c.clients))
+	for server, client := range c.clients {
+		server, client := server, client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := call(ctx, client)
+			respChan <- consensusResponse[T]{server, result, err}
+		}()
+	}
+
+	votes := make(map[string]int)
+	responses := make([]ServerResponse, 0, len(c.clients))
+	for range c.clients {
+		resp := <-respChan
+		responses = append(responses, ServerResponse{
+			Server: resp.server,
+			Result: resp.result,
+			Err:    resp.err,
+		})
+		if resp.err != nil {
+			continue
+		}
+
+		k := key(resp.result)
+		votes[k]++
+		if votes[k] >= c.quorum {
+			return resp.result, nil
+		}
+	}
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Server < responses[j].Server
+	})
+
+	var zero T
+	return zero, &DisagreementError{
+		Method:    method,
+		Quorum:    c.quorum,
+		Responses: responses,
+	}
+}
+
+// unorderedKey compares slices regardless of the order of their elements, as
+// servers may list mempool entries in any order.
+func unorderedKey[E any](elems []E) string {
+	keys := make([]string, len(elems))
+	for i, elem := range elems {
+		keys[i] = mustMarshalJSON(elem)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// GetBalance returns the balance of a scripthash agreed on by the quorum.
+func (c *Consensus) GetBalance(
+	ctx context.Context,
+	scripthash string,
+) (GetBalanceResult, error) {
+	return consensusCall(
+		ctx,
+		c,
+		"blockchain.scripthash.get_balance",
+		func(ctx context.Context, client *Client) (GetBalanceResult, error) {
+			return client.GetBalance(ctx, scripthash)
+		},
+		func(result GetBalanceResult) string {
+			return mustMarshalJSON(result)
+		},
+	)
+}
+
+// GetHistory returns the history of a scripthash agreed on by the quorum.
+func (c *Consensus) GetHistory(
+	ctx context.Context,
+	scripthash string,
+) ([]*GetMempoolResult, error) {
+	return consensusCall(
+		ctx,
+		c,
+		"blockchain.scripthash.get_history",
+		func(ctx context.Context, client *Client) ([]*GetMempoolResult, error) {
+			return client.GetHistory(ctx, scripthash)
+		},
+		unorderedKey[*GetMempoolResult],
+	)
+}
+
+// ListUnspent returns the UTXOs of a scripthash agreed on by the quorum.
+func (c *Consensus) ListUnspent(
+	ctx context.Context,
+	scripthash string,
+) ([]*ListUnspentResult, error) {
+	return consensusCall(
+		ctx,
+		c,
+		"blockchain.scripthash.listunspent",
+		func(ctx context.Context, client *Client) ([]*ListUnspentResult, error) {
+			return client.ListUnspent(ctx, scripthash)
+		},
+		unorderedKey[*ListUnspentResult],
+	)
+}
+
+// GetBlockHeader returns the block header at a specific height agreed on by
+// the quorum.
+func (c *Consensus) GetBlockHeader(
+	ctx context.Context,
+	height uint64,
+	checkpointHeight ...uint64,
+) (*GetBlockHeaderResult, error) {
+	return consensusCall(
+		ctx,
+		c,
+		"blockchain.block.header",
+		func(ctx context.Context, client *Client) (*GetBlockHeaderResult, error) {
+			return client.GetBlockHeader(ctx, height, checkpointHeight...)
+		},
+		func(result *GetBlockHeaderResult) string {
+			return mustMarshalJSON(result)
+		},
+	)
+}
@@ -0,0 +1,100 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusCall(t *testing.T) {
+	servers := []string{"a", "b", "c"}
+	answers := map[string]map[string]int{
+		"agree":    {"a": 1, "b": 1, "c": 2},
+		"disagree": {"a": 1, "b": 2, "c": 3},
+	}
+
+	for name, answer := range answers {
+		name, answer := name, answer
+		clients := make(map[string]*Client)
+		calls := make(map[*Client]string)
+		for _, server := range servers {
+			client := &Client{}
+			clients[server] = client
+			calls[client] = server
+		}
+
+		c, err := NewConsensus(2, clients)
+		require.NoError(t, err)
+
+		result, err := consensusCall(
+			context.Background(),
+			c,
+			"test",
+			func(ctx context.Context, client *Client) (int, error) {
+				return answer[calls[client]], nil
+			},
+			func(result int) string {
+				return mustMarshalJSON(result)
+			},
+		)
+
+		if name == "agree" {
+			require.NoError(t, err)
+			assert.Equal(t, 1, result)
+			continue
+		}
+
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrNoQuorum))
+		var disagreement *DisagreementError
+		require.True(t, errors.As(err, &disagreement))
+		assert.Len(t, disagreement.Responses, 3)
+		assert.Equal(t, "a", disagreement.Responses[0].Server)
+	}
+
+	_, err := NewConsensus(2, map[string]*Client{"a": {}})
+	assert.Equal(t, ErrInvalidQuorum, err)
+}
+
+func TestConsensusCallCancelsRemaining(t *testing.T) {
+	clients := map[string]*Client{"a": {}, "b": {}, "c": {}}
+	slow := clients["c"]
+
+	c, err := NewConsensus(2, clients)
+	require.NoError(t, err)
+
+	var cancelled int32
+	result, err := consensusCall(
+		context.Background(),
+		c,
+		"test",
+		func(ctx context.Context, client *Client) (int, error) {
+			if client != slow {
+				return 1, nil
+			}
+			<-ctx.Done()
+			atomic.StoreInt32(&cancelled, 1)
+			return 0, ctx.Err()
+		},
+		func(result int) string {
+			return mustMarshalJSON(result)
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result)
+
+	// The slow call was cancelled and had returned by then.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+}
+
+func TestUnorderedKey(t *testing.T) {
+	a := []*GetMempoolResult{{Hash: "aa", Height: 0}, {Hash: "bb", Height: 0}}
+	b := []*GetMempoolResult{{Hash: "bb", Height: 0}, {Hash: "aa", Height: 0}}
+
+	assert.Equal(t, unorderedKey(a), unorderedKey(b))
+	assert.NotEqual(t, unorderedKey(a), unorderedKey(a[:1]))
+}
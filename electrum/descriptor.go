@@ -0,0 +1,626 @@
+package electrum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// maxMultiSigKeys is the maximum number of keys of a multi() descriptor.
+	maxMultiSigKeys = 20
+)
+
+var (
+	// ErrInvalidDescriptor throws an error if an output descriptor cannot be parsed.
+	ErrInvalidDescriptor = errors.New("invalid output descriptor")
+
+	// ErrDescriptorChecksum throws an error if the checksum of an output
+	// descriptor does not match.
+	ErrDescriptorChecksum = errors.New("invalid output descriptor checksum")
+
+	// ErrDescriptorRange throws an error if a single script is requested from a
+	// ranged output descriptor, one with a wildcard derivation step.
+	ErrDescriptorRange = errors.New("output descriptor is ranged")
+)
+
+// descriptorContext is where a script expression is nested, which restricts
+// the allowed expressions and keys.
+type descriptorContext int
+
+const (
+	descriptorTop descriptorContext = iota
+	descriptorSH
+	descriptorWSH
+	descriptorTR
+)
+
+type scriptFunc func(index uint32) ([]byte, error)
+
+type keyFunc func(index uint32) ([]byte, error)
+
+// Descriptor is a parsed output descriptor, as defined in BIP380 and following.
+// Supported expressions are sh, wsh, pk, pkh, wpkh, multi, sortedmulti, tr
+// without script tree, addr and raw, with hex, WIF or extended keys.
+type Descriptor struct {
+	desc    string
+	isRange bool
+	script  scriptFunc
+}
+
+// ParseDescriptor parses an output descriptor, with or without checksum.
+// Addresses and keys are decoded for mainnet unless network params are given.
+func ParseDescriptor(
+	desc string,
+	params ...*chaincfg.Params,
+) (*Descriptor, error) {
+	network := &chaincfg.MainNetParams
+	if len(params) > 0 && params[0] != nil {
+		network = params[0]
+	}
+
+	if i := strings.LastIndexByte(desc, '#'); i >= 0 {
+		checksum, err := DescriptorChecksum(desc[:i])
+		if err != nil {
+			return nil, err
+		}
+		if checksum != desc[i+1:] {
+			return nil, ErrDescriptorChecksum
+		}
+		desc = desc[:i]
+	}
+
+	d := &Descriptor{desc: desc}
+	p := &descriptorParser{params: network, descriptor: d}
+
+	script, err := p.parseScript(desc, descriptorTop)
+	if err != nil {
+		return nil, err
+	}
+	d.script = script
+
+	return d, nil
+}
+
+// DescriptorToElectrumScriptHash converts a non ranged output descriptor to
+// an electrum scripthash.
+func DescriptorToElectrumScriptHash(
+	desc string,
+	params ...*chaincfg.Params,
+) (string, error) {
+	d, err := ParseDescriptor(desc, params...)
+	if err != nil {
+		return "", err
+	}
+
+	if d.IsRange() {
+		return "", ErrDescriptorRange
+	}
+
+	return d.ScriptHash(0)
+}
+
+// DescriptorToElectrumScriptHash converts a non ranged output descriptor of
+// the client network, or of the given network params, to an electrum scripthash.
+func (s *Client) DescriptorToElectrumScriptHash(
+	desc string,
+	params ...*chaincfg.Params,
+) (string, error) {
+	return DescriptorToElectrumScriptHash(desc, s.network(params))
+}
+
+// String returns the descriptor with its checksum.
+func (d *Descriptor) String() string {
+	checksum, _ := DescriptorChecksum(d.desc)
+	return d.desc + "#" + checksum
+}
+
+// IsRange reports whether the descriptor has a wildcard derivation step, in
+// which case index selects the derived child.
+func (d *Descriptor) IsRange() bool {
+	return d.isRange
+}
+
+// ScriptPubKey returns the output script at index, ignored if the descriptor
+// is not ranged.
+func (d *Descriptor) ScriptPubKey(index uint32) ([]byte, error) {
+	return d.script(index)
+}
+
+// ScriptHash returns the electrum scripthash of the output script at index.
+func (d *Descriptor) ScriptHash(index uint32) (string, error) {
+	script, err := d.script(index)
+	if err != nil {
+		return "", err
+	}
+
+	return ScriptToElectrumScriptHash(script), nil
+}
+
+// DescriptorChecksum computes the 8 character checksum of an output
+// descriptor, as defined in BIP380.
+func DescriptorChecksum(desc string) (string, error) {
+	polymod := func(c uint64, val uint64) uint64 {
+		c0 := c >> 35
+		c = ((c & 0x7ffffffff) << 5) ^ val
+		if c0&1 != 0 {
+			c ^= 0xf5dee51989
+		}
+		if c0&2 != 0 {
+			c ^= 0xa9fdca3312
+		}
+		if c0&4 != 0 {
+			c ^= 0x1bab10e32d
+		}
+		if c0&8 != 0 {
+			c ^= 0x3706b1677a
+		}
+		if c0&16 != 0 {
+			c ^= 0x644d626ffd
+		}
+		return c
+	}
+
+	c := uint64(1)
+	cls, clsCount := uint64(0), 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf(
+				"%w: invalid character %q",
+				ErrInvalidDescriptor,
+				ch,
+			)
+		}
+
+		c = polymod(c, uint64(pos&31))
+		cls = cls*3 + uint64(pos>>5)
+		clsCount++
+		if clsCount == 3 {
+			c = polymod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polymod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = polymod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, 8)
+	for i := range checksum {
+		checksum[i] = descriptorChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+
+	return string(checksum), nil
+}
+
+type descriptorParser struct {
+	params     *chaincfg.Params
+	descriptor *Descriptor
+}
+
+func (p *descriptorParser) parseScript(
+	expr string,
+	ctx descriptorContext,
+) (scriptFunc, error) {
+	name, args, err := splitDescriptorExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "sh":
+		if ctx != descriptorTop {
+			return nil, invalidDescriptor("sh() must be top level")
+		}
+
+		inner, err := p.parseScript(args, descriptorSH)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(index uint32) ([]byte, error) {
+			script, err := inner(index)
+			if err != nil {
+				return nil, err
+			}
+			return txscript.NewScriptBuilder().
+				AddOp(txscript.OP_HASH160).
+				AddData(btcutil.Hash160(script)).
+				AddOp(txscript.OP_EQUAL).
+				Script()
+		}, nil
+
+	case "wsh":
+		if ctx != descriptorTop && ctx != descriptorSH {
+			return nil, invalidDescriptor("wsh() must be top level or in sh()")
+		}
+
+		inner, err := p.parseScript(args, descriptorWSH)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(index uint32) ([]byte, error) {
+			script, err := inner(index)
+			if err != nil {
+				return nil, err
+			}
+			hash := sha256.Sum256(script)
+			return txscript.NewScriptBuilder().
+				AddOp(txscript.OP_0).
+				AddData(hash[:]).
+				Script()
+		}, nil
+
+	case "pk":
+		key, err := p.parseKey(args, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(index uint32) ([]byte, error) {
+			pubKey, err := key(index)
+			if err != nil {
+				return nil, err
+			}
+			return txscript.NewScriptBuilder().
+				AddData(pubKey).
+				AddOp(txscript.OP_CHECKSIG).
+				Script()
+		}, nil
+
+	case "pkh":
+		key, err := p.parseKey(args, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(index uint32) ([]byte, error) {
+			pubKey, err := key(index)
+			if err != nil {
+				return nil, err
+			}
+			return txscript.NewScriptBuilder().
+				AddOp(txscript.OP_DUP).
+				AddOp(txscript.OP_HASH160).
+				AddData(btcutil.Hash160(pubKey)).
+				AddOp(txscript.OP_EQUALVERIFY).
+				AddOp(txscript.OP_CHECKSIG).
+				Script()
+		}, nil
+
+	case "wpkh":
+		if ctx != descriptorTop && ctx != descriptorSH {
+			return nil, invalidDescriptor("wpkh() must be top level or in sh()")
+		}
+
+		key, err := p.parseKey(args, descriptorWSH)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(index uint32) ([]byte, error) {
+			pubKey, err := key(index)
+			if err != nil {
+				return nil, err
+			}
+			return txscript.NewScriptBuilder().
+				AddOp(txscript.OP_0).
+				AddData(btcutil.Hash160(pubKey)).
+				Script()
+		}, nil
+
+	case "multi", "sortedmulti":
+		return p.parseMulti(args, ctx, name == "sortedmulti")
+
+	case "tr":
+		if ctx != descriptorTop {
+			return nil, invalidDescriptor("tr() must be top level")
+		}
+		if strings.ContainsRune(args, ',') {
+			return nil, invalidDescriptor("tr() script trees are not supported")
+		}
+
+		key, err := p.parseKey(args, descriptorTR)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(index uint32) ([]byte, error) {
+			pubKey, err := key(index)
+			if err != nil {
+				return nil, err
+			}
+			internalKey, err := schnorr.ParsePubKey(pubKey)
+			if err != nil {
+				return nil, err
+			}
+			outputKey := txscript.ComputeTaprootKeyNoScript(internalKey)
+			return txscript.NewScriptBuilder().
+				AddOp(txscript.OP_1).
+				AddData(schnorr.SerializePubKey(outputKey)).
+				Script()
+		}, nil
+
+	case "addr":
+		if ctx != descriptorTop {
+			return nil, invalidDescriptor("addr() must be top level")
+		}
+
+		address, err := btcutil.DecodeAddress(args, p.params)
+		if err != nil {
+			return nil, err
+		}
+		if !address.IsForNet(p.params) {
+			return nil, fmt.Errorf(
+				"address %s is not for %s",
+				args,
+				p.params.Name,
+			)
+		}
+
+		script, err := txscript.PayToAddrScript(address)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(uint32) ([]byte, error) {
+			return script, nil
+		}, nil
+
+	case "raw":
+		if ctx != descriptorTop {
+			return nil, invalidDescriptor("raw() must be top level")
+		}
+
+		script, err := hex.DecodeString(args)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(uint32) ([]byte, error) {
+			return script, nil
+		}, nil
+	}
+
+	return nil, invalidDescriptor("unknown expression " + name)
+}
+
+func (p *descriptorParser) parseMulti(
+	args string,
+	ctx descriptorContext,
+	sorted bool,
+) (scriptFunc, error) {
+	parts := splitDescriptorArgs(args)
+	if len(parts) < 2 {
+		return nil, invalidDescriptor("multi() requires a threshold and keys")
+	}
+
+	required, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, invalidDescriptor("invalid multi() threshold")
+	}
+
+	keys := make([]keyFunc, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		key, err := p.parseKey(part, ctx)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if required < 1 || required > len(keys) || len(keys) > maxMultiSigKeys {
+		return nil, invalidDescriptor("invalid multi() threshold")
+	}
+
+	return func(index uint32) ([]byte, error) {
+		pubKeys := make([][]byte, 0, len(keys))
+		for _, key := range keys {
+			pubKey, err := key(index)
+			if err != nil {
+				return nil, err
+			}
+			pubKeys = append(pubKeys, pubKey)
+		}
+
+		if sorted {
+			sort.Slice(pubKeys, func(i, j int) bool {
+				return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+			})
+		}
+
+		builder := txscript.NewScriptBuilder().AddInt64(int64(required))
+		for _, pubKey := range pubKeys {
+			builder.AddData(pubKey)
+		}
+		return builder.
+			AddInt64(int64(len(pubKeys))).
+			AddOp(txscript.OP_CHECKMULTISIG).
+			Script()
+	}, nil
+}
+
+// parseKey parses a key expression to a function returning the serialized
+// public key: compressed in segwit scripts, x-only in tr().
+func (p *descriptorParser) parseKey(
+	expr string,
+	ctx descriptorContext,
+) (keyFunc, error) {
+	// Key origins only document where the key comes from.
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end < 0 {
+			return nil, invalidDescriptor("unterminated key origin")
+		}
+		expr = expr[end+1:]
+	}
+
+	serialize := func(pubKey *btcec.PublicKey, compressed bool) ([]byte, error) {
+		switch {
+		case ctx == descriptorTR:
+			return schnorr.SerializePubKey(pubKey), nil
+		case compressed:
+			return pubKey.SerializeCompressed(), nil
+		case ctx == descriptorWSH:
+			return nil, invalidDescriptor("uncompressed key in segwit script")
+		}
+		return pubKey.SerializeUncompressed(), nil
+	}
+
+	fixed := func(pubKey []byte) keyFunc {
+		return func(uint32) ([]byte, error) {
+			return pubKey, nil
+		}
+	}
+
+	if b, err := hex.DecodeString(expr); err == nil {
+		if ctx == descriptorTR && len(b) == schnorr.PubKeyBytesLen {
+			pubKey, err := schnorr.ParsePubKey(b)
+			if err != nil {
+				return nil, err
+			}
+			return fixed(schnorr.SerializePubKey(pubKey)), nil
+		}
+
+		pubKey, err := btcec.ParsePubKey(b)
+		if err != nil {
+			return nil, err
+		}
+		serialized, err := serialize(
+			pubKey,
+			len(b) == btcec.PubKeyBytesLenCompressed,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return fixed(serialized), nil
+	}
+
+	if wif, err := btcutil.DecodeWIF(expr); err == nil {
+		serialized, err := serialize(wif.PrivKey.PubKey(), wif.CompressPubKey)
+		if err != nil {
+			return nil, err
+		}
+		return fixed(serialized), nil
+	}
+
+	steps := strings.Split(expr, "/")
+	extKey, err := hdkeychain.NewKeyFromString(steps[0])
+	if err != nil {
+		return nil, invalidDescriptor("invalid key " + expr)
+	}
+
+	var wildcard, hardenedWildcard bool
+	for i, step := range steps[1:] {
+		hardened := strings.HasSuffix(step, "'") || strings.HasSuffix(step, "h")
+		if hardened {
+			step = step[:len(step)-1]
+		}
+
+		if step == "*" {
+			if i != len(steps)-2 {
+				return nil, invalidDescriptor("wildcard must be the last step")
+			}
+			wildcard, hardenedWildcard = true, hardened
+			break
+		}
+
+		child, err := strconv.ParseUint(step, 10, 31)
+		if err != nil {
+			return nil, invalidDescriptor("invalid derivation step " + step)
+		}
+		if hardened {
+			child += hdkeychain.HardenedKeyStart
+		}
+
+		extKey, err = extKey.Derive(uint32(child))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	derive := func(extKey *hdkeychain.ExtendedKey) ([]byte, error) {
+		pubKey, err := extKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		return serialize(pubKey, true)
+	}
+
+	if !wildcard {
+		serialized, err := derive(extKey)
+		if err != nil {
+			return nil, err
+		}
+		return fixed(serialized), nil
+	}
+
+	p.descriptor.isRange = true
+	return func(index uint32) ([]byte, error) {
+		if hardenedWildcard {
+			index += hdkeychain.HardenedKeyStart
+		}
+		child, err := extKey.Derive(index)
+		if err != nil {
+			return nil, err
+		}
+		return derive(child)
+	}, nil
+}
+
+// splitDescriptorExpr splits name(args) into its name and arguments.
+func splitDescriptorExpr(expr string) (string, string, error) {
+	open := strings.IndexByte(expr, '(')
+	if open < 0 || !strings.HasSuffix(expr, ")") {
+		return "", "", invalidDescriptor("malformed expression " + expr)
+	}
+
+	return expr[:open], expr[open+1 : len(expr)-1], nil
+}
+
+// splitDescriptorArgs splits arguments on the commas not nested in an
+// expression or key origin.
+func splitDescriptorArgs(args string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, ch := range args {
+		switch ch {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, args[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, args[start:])
+}
+
+func invalidDescriptor(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidDescriptor, reason)
+}
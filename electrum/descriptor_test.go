@@ -0,0 +1,117 @@
+package electrum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPubKey1 = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	testPubKey2 = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	testPubKey3 = "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	checksum, err := DescriptorChecksum("raw(deadbeef)")
+	require.NoError(t, err)
+	assert.Equal(t, "89f8spxm", checksum)
+
+	_, err = ParseDescriptor("raw(deadbeef)#89f8spxm")
+	assert.NoError(t, err)
+
+	_, err = ParseDescriptor("raw(deadbeef)#89f8spxn")
+	assert.ErrorIs(t, err, ErrDescriptorChecksum)
+}
+
+func TestDescriptorToElectrumScriptHash(t *testing.T) {
+	// BIP84 first receive address.
+	scriptHash, err := DescriptorToElectrumScriptHash(
+		"wpkh([73c5da0a/84'/0'/0']0330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3c)",
+	)
+	require.NoError(t, err)
+	want, err := AddressToElectrumScriptHash(
+		"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, want, scriptHash)
+
+	// P2SH-P2WSH 2-of-3 multisig.
+	scriptHash, err = DescriptorToElectrumScriptHash(
+		"sh(wsh(multi(2," + testPubKey1 + "," + testPubKey2 + "," + testPubKey3 + ")))",
+	)
+	require.NoError(t, err)
+
+	var pubKeys []*btcutil.AddressPubKey
+	for _, key := range []string{testPubKey1, testPubKey2, testPubKey3} {
+		b, _ := hex.DecodeString(key)
+		pubKey, err := btcutil.NewAddressPubKey(b, &chaincfg.MainNetParams)
+		require.NoError(t, err)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	multiSig, err := txscript.MultiSigScript(pubKeys, 2)
+	require.NoError(t, err)
+	witnessHash := sha256.Sum256(multiSig)
+	wsh, err := btcutil.NewAddressWitnessScriptHash(
+		witnessHash[:],
+		&chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	wshScript, err := txscript.PayToAddrScript(wsh)
+	require.NoError(t, err)
+	sh, err := btcutil.NewAddressScriptHash(wshScript, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	want, err = AddressToElectrumScriptHash(sh.EncodeAddress())
+	require.NoError(t, err)
+	assert.Equal(t, want, scriptHash)
+
+	// Bare multisig and P2PK have no address.
+	b1, _ := hex.DecodeString(testPubKey1)
+	b2, _ := hex.DecodeString(testPubKey2)
+	scriptHash, err = DescriptorToElectrumScriptHash(
+		"multi(1," + testPubKey1 + "," + testPubKey2 + ")",
+	)
+	require.NoError(t, err)
+	want, err = MultiSigToElectrumScriptHash(1, b1, b2)
+	require.NoError(t, err)
+	assert.Equal(t, want, scriptHash)
+
+	scriptHash, err = DescriptorToElectrumScriptHash("pk(" + testPubKey1 + ")")
+	require.NoError(t, err)
+	want, err = PubKeyToElectrumScriptHash(b1)
+	require.NoError(t, err)
+	assert.Equal(t, want, scriptHash)
+
+	_, err = DescriptorToElectrumScriptHash("wsh(wpkh(" + testPubKey1 + "))")
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+}
+
+func TestDescriptorRange(t *testing.T) {
+	// BIP86 account 0, first receive output.
+	d, err := ParseDescriptor(
+		"tr(xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ/0/*)",
+	)
+	require.NoError(t, err)
+	assert.True(t, d.IsRange())
+
+	script, err := d.ScriptPubKey(0)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c",
+		hex.EncodeToString(script),
+	)
+
+	scriptHash, err := d.ScriptHash(0)
+	require.NoError(t, err)
+	assert.Equal(t, ScriptToElectrumScriptHash(script), scriptHash)
+
+	_, err = DescriptorToElectrumScriptHash(d.String())
+	assert.ErrorIs(t, err, ErrDescriptorRange)
+}
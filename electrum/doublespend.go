@@ -0,0 +1,346 @@
+package electrum
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ConflictEvent reports a transaction spending some of the outpoints of a
+// watched transaction, either a replacement or a double spend.
+type ConflictEvent struct {
+	TxID            string
+	ConflictingTxID string
+	// Outpoints are the outpoints spent by both transactions.
+	Outpoints []wire.OutPoint
+	// Height is the height of the conflicting transaction, 0 if unconfirmed.
+	Height int64
+	// Replaced tells whether the watched transaction is no longer known to
+	// the remote server.
+	Replaced bool
+}
+
+// DoubleSpendMonitor detects conflicting transactions of watched payments
+// from the scripthash history of the outputs they spend, with a single
+// scripthash subscription.
+type DoubleSpendMonitor struct {
+	client  *Client
+	sub     *ScripthashSubscription
+	watches map[string][]*spendWatch // by scripthash of spent outputs
+
+	lock sync.Mutex
+}
+
+// spendWatch is the state of a watched transaction.
+type spendWatch struct {
+	ctx          context.Context
+	txID         string
+	outpoints    map[wire.OutPoint]struct{}
+	scripthashes []string
+	events       chan *ConflictEvent
+	// checked holds the transactions already compared with the watched one.
+	checked map[string]struct{}
+	done    bool
+
+	lock sync.Mutex
+}
+
+// NewDoubleSpendMonitor creates a monitor. It runs until the client is shut
+// down.
+func (s *Client) NewDoubleSpendMonitor() *DoubleSpendMonitor {
+	sub, notifs := s.SubscribeScripthash()
+	m := &DoubleSpendMonitor{
+		client:  s,
+		sub:     sub,
+		watches: make(map[string][]*spendWatch),
+	}
+
+	go m.run(notifs)
+
+	return m
+}
+
+// WatchDoubleSpend watches a transaction for conflicts, using a monitor
+// shared by every call on the client.
+func (s *Client) WatchDoubleSpend(
+	ctx context.Context,
+	txID string,
+) (<-chan *ConflictEvent, error) {
+	s.doubleSpendLock.Lock()
+	if s.doubleSpend == nil {
+		s.doubleSpend = s.NewDoubleSpendMonitor()
+	}
+	m := s.doubleSpend
+	s.doubleSpendLock.Unlock()
+
+	return m.Watch(ctx, txID)
+}
+
+// Watch emits an event on the returned channel for every transaction found
+// conflicting with txID, until ctx is done.
+func (m *DoubleSpendMonitor) Watch(
+	ctx context.Context,
+	txID string,
+) (<-chan *ConflictEvent, error) {
+	// Only the inputs are needed, not the block fields.
+	tx, err := m.client.getTransaction(ctx, txID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	watch := &spendWatch{
+		ctx:       ctx,
+		txID:      txID,
+		outpoints: make(map[wire.OutPoint]struct{}),
+		events:    make(chan *ConflictEvent, 16),
+		checked:   map[string]struct{}{txID: {}},
+	}
+
+	var prevTxHashes []string
+	for _, vin := range tx.Vin {
+		if vin.IsCoinbase() {
+			continue
+		}
+
+		hash, err := chainhash.NewHashFromStr(vin.TxID)
+		if err != nil {
+			return nil, err
+		}
+		watch.outpoints[*wire.NewOutPoint(hash, vin.Vout)] = struct{}{}
+		prevTxHashes = append(prevTxHashes, vin.TxID)
+		// The transactions funding the watched one cannot conflict with it.
+		watch.checked[vin.TxID] = struct{}{}
+	}
+
+	prevTxs, err := m.client.getTransactions(ctx, prevTxHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	for outpoint := range watch.outpoints {
+		prevTx := prevTxs[outpoint.Hash.String()]
+		if prevTx == nil || int(outpoint.Index) >= len(prevTx.Vout) {
+			return nil, fmt.Errorf(
+				"prevout %s of tx %s not found",
+				outpoint,
+				txID,
+			)
+		}
+
+		script, err := hex.DecodeString(
+			prevTx.Vout[outpoint.Index].ScriptPubKey.Hex,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		scripthash := ScriptToElectrumScriptHash(script)
+		if _, ok := seen[scripthash]; !ok {
+			seen[scripthash] = struct{}{}
+			watch.scripthashes = append(watch.scripthashes, scripthash)
+		}
+	}
+
+	var subscribe []string
+	m.lock.Lock()
+	for _, scripthash := range watch.scripthashes {
+		if len(m.watches[scripthash]) == 0 {
+			subscribe = append(subscribe, scripthash)
+		}
+		m.watches[scripthash] = append(m.watches[scripthash], watch)
+	}
+	m.lock.Unlock()
+
+	for _, scripthash := range subscribe {
+		err = m.sub.Add(ctx, scripthash)
+		if err != nil {
+			m.stop(watch)
+			return nil, err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		m.stop(watch)
+	}()
+
+	for _, scripthash := range watch.scripthashes {
+		go m.check(watch, scripthash)
+	}
+
+	return watch.events, nil
+}
+
+func (m *DoubleSpendMonitor) run(notifs <-chan *SubscribeNotif) {
+	for {
+		select {
+		case <-m.client.quit:
+			return
+
+		case notif := <-notifs:
+			scripthash := notif.Params[0]
+
+			m.lock.Lock()
+			watches := append([]*spendWatch(nil), m.watches[scripthash]...)
+			m.lock.Unlock()
+
+			for _, watch := range watches {
+				go m.check(watch, scripthash)
+			}
+		}
+	}
+}
+
+// check looks for transactions spending the watched outpoints among the
+// mempool of a spent scripthash, or its whole history once the watched
+// transaction left the mempool.
+func (m *DoubleSpendMonitor) check(watch *spendWatch, scripthash string) {
+	watch.lock.Lock()
+	defer watch.lock.Unlock()
+
+	if watch.done {
+		return
+	}
+
+	entries, err := m.client.GetMempool(watch.ctx, scripthash)
+	if err != nil {
+		m.client.logger.Errorf(
+			"Get mempool of tx %s inputs failed: %v",
+			watch.txID,
+			err,
+		)
+		return
+	}
+
+	known := containsTx(entries, watch.txID)
+	if !known {
+		entries, err = m.client.GetHistory(watch.ctx, scripthash)
+		if err != nil {
+			m.client.logger.Errorf(
+				"Get history of tx %s inputs failed: %v",
+				watch.txID,
+				err,
+			)
+			return
+		}
+		known = containsTx(entries, watch.txID)
+	}
+
+	heights := make(map[string]int64)
+	var candidates []string
+	for _, entry := range entries {
+		if _, ok := watch.checked[entry.Hash]; ok {
+			continue
+		}
+		heights[entry.Hash] = entry.Height
+		candidates = append(candidates, entry.Hash)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	txs, err := m.client.getTransactions(watch.ctx, candidates)
+	if err != nil {
+		m.client.logger.Errorf(
+			"Get conflict candidates of tx %s failed: %v",
+			watch.txID,
+			err,
+		)
+		return
+	}
+
+	for _, txHash := range candidates {
+		// A transaction the server did not return is examined again on
+		// the next notification.
+		tx := txs[txHash]
+		if tx == nil {
+			continue
+		}
+		watch.checked[txHash] = struct{}{}
+
+		var outpoints []wire.OutPoint
+		for _, vin := range tx.Vin {
+			hash, err := chainhash.NewHashFromStr(vin.TxID)
+			if vin.IsCoinbase() || err != nil {
+				continue
+			}
+
+			outpoint := wire.OutPoint{Hash: *hash, Index: vin.Vout}
+			if _, ok := watch.outpoints[outpoint]; ok {
+				outpoints = append(outpoints, outpoint)
+			}
+		}
+		if len(outpoints) == 0 {
+			continue
+		}
+
+		height := heights[txHash]
+		if height < 0 {
+			height = 0
+		}
+
+		select {
+		case watch.events <- &ConflictEvent{
+			TxID:            watch.txID,
+			ConflictingTxID: txHash,
+			Outpoints:       outpoints,
+			Height:          height,
+			Replaced:        !known,
+		}:
+		case <-watch.ctx.Done():
+			return
+		}
+	}
+}
+
+func containsTx(entries []*GetMempoolResult, txID string) bool {
+	for _, entry := range entries {
+		if entry.Hash == txID {
+			return true
+		}
+	}
+	return false
+}
+
+// stop closes the events of a watch and stops watching it.
+func (m *DoubleSpendMonitor) stop(watch *spendWatch) {
+	watch.lock.Lock()
+	if watch.done {
+		watch.lock.Unlock()
+		return
+	}
+	watch.done = true
+	close(watch.events)
+	watch.lock.Unlock()
+
+	var unsubscribe []string
+	m.lock.Lock()
+	for _, scripthash := range watch.scripthashes {
+		watches := m.watches[scripthash]
+		for i, other := range watches {
+			if other == watch {
+				watches = append(watches[:i:i], watches[i+1:]...)
+				break
+			}
+		}
+
+		if len(watches) > 0 {
+			m.watches[scripthash] = watches
+			continue
+		}
+		delete(m.watches, scripthash)
+		unsubscribe = append(unsubscribe, scripthash)
+	}
+	m.lock.Unlock()
+
+	// Not under the monitor lock, the subscription may be blocked
+	// delivering a notification to run.
+	for _, scripthash := range unsubscribe {
+		_ = m.sub.Remove(scripthash)
+	}
+}
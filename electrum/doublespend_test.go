@@ -0,0 +1,144 @@
+package electrum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spentOutputs is a confirmed funding transaction with two outputs paying
+// the same script, the first spent by a watched transaction in the mempool.
+type spentOutputs struct {
+	server  *txServer
+	script  []byte
+	funding *wire.MsgTx
+	watched string
+}
+
+func newSpentOutputs() *spentOutputs {
+	s := &spentOutputs{server: newTxServer(100), script: testScript(1)}
+
+	s.funding = newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{1}}},
+		s.script,
+		s.script,
+	)
+	s.server.add(s.funding, 50)
+	s.watched = s.server.add(s.spend(0, 2), 0)
+
+	return s
+}
+
+// spend returns a transaction spending output index of the funding
+// transaction to a script of id.
+func (s *spentOutputs) spend(index uint32, id byte) *wire.MsgTx {
+	return newTestTx(
+		[]wire.OutPoint{s.outpoint(index)},
+		testScript(id),
+	)
+}
+
+func (s *spentOutputs) outpoint(index uint32) wire.OutPoint {
+	return wire.OutPoint{Hash: s.funding.TxHash(), Index: index}
+}
+
+func (s *spentOutputs) watch(t *testing.T) <-chan *ConflictEvent {
+	client := newFakeClient(t, s.server.fakeServer, WithRawTransactions())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	events, err := client.WatchDoubleSpend(ctx, s.watched)
+	require.NoError(t, err)
+	return events
+}
+
+func nextConflict(t *testing.T, events <-chan *ConflictEvent) *ConflictEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no conflict event")
+		return nil
+	}
+}
+
+func TestWatchDoubleSpendReplaced(t *testing.T) {
+	spent := newSpentOutputs()
+	events := spent.watch(t)
+
+	spent.server.remove(spent.watched)
+	conflict := spent.server.add(spent.spend(0, 3), 0)
+	spent.server.notify(spent.script)
+
+	assert.Equal(
+		t,
+		&ConflictEvent{
+			TxID:            spent.watched,
+			ConflictingTxID: conflict,
+			Outpoints:       []wire.OutPoint{spent.outpoint(0)},
+			Replaced:        true,
+		},
+		nextConflict(t, events),
+	)
+}
+
+func TestWatchDoubleSpendConfirmed(t *testing.T) {
+	spent := newSpentOutputs()
+	events := spent.watch(t)
+
+	spent.server.remove(spent.watched)
+	conflict := spent.server.add(spent.spend(0, 3), 101)
+	spent.server.mine(101)
+	spent.server.notify(spent.script)
+
+	assert.Equal(
+		t,
+		&ConflictEvent{
+			TxID:            spent.watched,
+			ConflictingTxID: conflict,
+			Outpoints:       []wire.OutPoint{spent.outpoint(0)},
+			Height:          101,
+			Replaced:        true,
+		},
+		nextConflict(t, events),
+	)
+}
+
+func TestWatchDoubleSpendSibling(t *testing.T) {
+	spent := newSpentOutputs()
+	events := spent.watch(t)
+
+	// The other output of the funding transaction is spent, which shares
+	// the scripthash of the watched outpoint without conflicting.
+	spent.server.add(spent.spend(1, 3), 0)
+	spent.server.notify(spent.script)
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A later conflict is still the only one reported.
+	spent.server.remove(spent.watched)
+	conflict := spent.server.add(spent.spend(0, 4), 0)
+	spent.server.notify(spent.script)
+
+	event := nextConflict(t, events)
+	assert.Equal(t, conflict, event.ConflictingTxID)
+	assert.True(t, event.Replaced)
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,167 @@
+package electrum
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JSON-RPC error codes used by ElectrumX compatible servers.
+const (
+	CodeParseError             = -32700
+	CodeInvalidRequest         = -32600
+	CodeMethodNotFound         = -32601
+	CodeInvalidParams          = -32602
+	CodeInternalError          = -32603
+	CodeExcessiveResourceUsage = -101
+	CodeBadRequest             = 1
+	CodeDaemonError            = 2
+)
+
+var (
+	// ErrMethodNotFound throws an error if the remote server does not know
+	// the called method.
+	ErrMethodNotFound = errors.New("method not found")
+
+	// ErrExcessiveResourceUsage throws an error if the remote server refuses
+	// a request because the session used too many resources.
+	ErrExcessiveResourceUsage = errors.New("excessive resource usage")
+
+	// ErrHistoryTooLarge throws an error if the history of a scripthash is
+	// too large for the remote server to return it.
+	ErrHistoryTooLarge = errors.New("history too large")
+
+	// ErrTxAlreadyInChain throws an error if a broadcasted transaction is
+	// already mined.
+	ErrTxAlreadyInChain = errors.New("transaction already in block chain")
+
+	// ErrTxAlreadyInMempool throws an error if a broadcasted transaction is
+	// already in the mempool.
+	ErrTxAlreadyInMempool = errors.New("transaction already in mempool")
+
+	// ErrMinRelayFeeNotMet throws an error if the fee of a broadcasted
+	// transaction is below the minimum relay or mempool fee.
+	ErrMinRelayFeeNotMet = errors.New("min relay fee not met")
+
+	// ErrInsufficientFee throws an error if the fee of a broadcasted
+	// transaction is too low to replace the transactions it conflicts with.
+	ErrInsufficientFee = errors.New("insufficient fee")
+
+	// ErrMissingInputs throws an error if the inputs of a broadcasted
+	// transaction are unknown or already spent.
+	ErrMissingInputs = errors.New("missing inputs")
+
+	// ErrMempoolConflict throws an error if a broadcasted transaction
+	// conflicts with a mempool transaction it cannot replace.
+	ErrMempoolConflict = errors.New("transaction conflicts with mempool")
+
+	// ErrDust throws an error if a broadcasted transaction has dust outputs.
+	ErrDust = errors.New("dust output")
+)
+
+// rpcErrorClass tells which server errors match a sentinel error, by code
+// or by a lowercase fragment of their message.
+type rpcErrorClass struct {
+	codes     []int
+	fragments []string
+}
+
+var rpcErrorClasses = map[error]rpcErrorClass{
+	ErrMethodNotFound: {
+		codes:     []int{CodeMethodNotFound},
+		fragments: []string{"unknown method"},
+	},
+	ErrExcessiveResourceUsage: {
+		codes:     []int{CodeExcessiveResourceUsage},
+		fragments: []string{"excessive resource usage"},
+	},
+	ErrHistoryTooLarge: {
+		fragments: []string{"history too large"},
+	},
+	ErrTxAlreadyInChain: {
+		fragments: []string{
+			"already in block chain",
+			"outputs already in utxo set",
+		},
+	},
+	ErrTxAlreadyInMempool: {
+		fragments: []string{"txn-already-in-mempool", "txn-already-known"},
+	},
+	ErrMinRelayFeeNotMet: {
+		fragments: []string{"min relay fee not met", "mempool min fee not met"},
+	},
+	ErrInsufficientFee: {
+		fragments: []string{"insufficient fee"},
+	},
+	ErrMissingInputs: {
+		fragments: []string{
+			"missing inputs",
+			"missing-inputs",
+			"inputs-missingorspent",
+		},
+	},
+	ErrMempoolConflict: {
+		fragments: []string{"txn-mempool-conflict"},
+	},
+	ErrDust: {
+		fragments: []string{"dust"},
+	},
+}
+
+// RPCError is an error answered by the remote server to a request. It
+// matches the sentinel errors of this package with errors.Is, e.g.
+// ErrMissingInputs for a rejected broadcast.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Method and ID identify the request the error answers.
+	Method string `json:"-"`
+	ID     uint64 `json:"-"`
+}
+
+func (e *RPCError) Error() string {
+	if e.Method == "" {
+		return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
+// Is reports whether the error belongs to the class of a sentinel error.
+func (e *RPCError) Is(target error) bool {
+	class, ok := rpcErrorClasses[target]
+	if !ok {
+		return false
+	}
+
+	for _, code := range class.codes {
+		if e.Code == code {
+			return true
+		}
+	}
+
+	message := strings.ToLower(e.Message)
+	for _, fragment := range class.fragments {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTxRejected reports whether err is the rejection of a transaction
+// broadcast by the remote server, rather than a transport failure.
+func IsTxRejected(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) &&
+		rpcErr.Method == "blockchain.transaction.broadcast"
+}
+
+// withMethod sets the method of the request an RPCError answers.
+func withMethod(err error, method string) error {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		rpcErr.Method = method
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package electrum
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCErrorIs(t *testing.T) {
+	tests := []struct {
+		code    int
+		message string
+		target  error
+	}{
+		{
+			CodeDaemonError,
+			"Transaction already in block chain",
+			ErrTxAlreadyInChain,
+		},
+		{
+			CodeDaemonError,
+			"the transaction was rejected by network rules.\n\n" +
+				"min relay fee not met, 100 < 141\n[0200...]",
+			ErrMinRelayFeeNotMet,
+		},
+		{
+			CodeDaemonError,
+			"bad-txns-inputs-missingorspent",
+			ErrMissingInputs,
+		},
+		{CodeBadRequest, "history too large", ErrHistoryTooLarge},
+		{CodeMethodNotFound, "unknown method \"foo\"", ErrMethodNotFound},
+		{CodeExcessiveResourceUsage, "", ErrExcessiveResourceUsage},
+	}
+
+	for _, test := range tests {
+		err := fmt.Errorf("broadcast: %w", &RPCError{
+			Code:    test.code,
+			Message: test.message,
+		})
+		assert.ErrorIs(t, err, test.target, test.message)
+	}
+
+	err := &RPCError{Code: CodeDaemonError, Message: "txn-mempool-conflict"}
+	assert.ErrorIs(t, err, ErrMempoolConflict)
+	assert.NotErrorIs(t, err, ErrMissingInputs)
+	assert.NotErrorIs(t, err, ErrTimeout)
+}
+
+func TestHandleResponseError(t *testing.T) {
+	client := &Client{
+		handlers:     make(map[uint64]chan *container),
+		pushHandlers: make(map[string][]chan *container),
+	}
+
+	c := client.registerHandler(7)
+	client.handleResponse([]byte(
+		`{"jsonrpc":"2.0","id":7,"error":{"code":2,"message":"dust"}}`,
+	))
+
+	resp := <-c
+	err := withMethod(resp.err, "blockchain.transaction.broadcast")
+
+	var rpcErr *RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, CodeDaemonError, rpcErr.Code)
+	assert.Equal(t, uint64(7), rpcErr.ID)
+	assert.Equal(
+		t,
+		"blockchain.transaction.broadcast: dust (code 2)",
+		err.Error(),
+	)
+	assert.ErrorIs(t, err, ErrDust)
+	assert.True(t, IsTxRejected(err))
+	assert.False(t, IsTxRejected(ErrTimeout))
+}
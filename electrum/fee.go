@@ -0,0 +1,65 @@
+package electrum
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// maxRBFSequence is the highest input sequence signaling replaceability,
+// as defined in BIP125.
+const maxRBFSequence = wire.MaxTxInSequenceNum - 2
+
+// SignalsRBF reports whether the transaction signals BIP125 replaceability,
+// that is whether any of its inputs has a sequence below 0xfffffffe.
+func (tx *GetTransactionResult) SignalsRBF() bool {
+	for _, vin := range tx.Vin {
+		if !vin.IsCoinbase() && vin.Sequence <= maxRBFSequence {
+			return true
+		}
+	}
+	return false
+}
+
+// fillSize sets the virtual size and weight of a transaction from its raw
+// hex when the remote server did not return them.
+func (tx *GetTransactionResult) fillSize() error {
+	if tx.Vsize > 0 && tx.Weight > 0 || tx.Hex == "" {
+		return nil
+	}
+
+	b, err := hex.DecodeString(tx.Hex)
+	if err != nil {
+		return err
+	}
+
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	tx.setSize(&msgTx)
+	return nil
+}
+
+func (tx *GetTransactionResult) setSize(msgTx *wire.MsgTx) {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(msgTx))
+	tx.Size = uint32(msgTx.SerializeSize())
+	tx.Weight = uint32(weight)
+	tx.Vsize = uint32(
+		(weight + blockchain.WitnessScaleFactor - 1) /
+			blockchain.WitnessScaleFactor,
+	)
+}
+
+// feeRate returns the fee rate in sat/vB, 0 if the size is unknown.
+func feeRate(fee btcutil.Amount, vsize uint32) float64 {
+	if vsize == 0 {
+		return 0
+	}
+	return float64(fee) / float64(vsize)
+}
@@ -0,0 +1,310 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// defaultFollowDepth is the number of recent headers a HeaderFollower keeps
+// when no depth is given.
+const defaultFollowDepth = 100
+
+// ChainHeader is a block header with its height.
+type ChainHeader struct {
+	Height int64
+	Header *wire.BlockHeader
+}
+
+// HeaderEvent is emitted by a HeaderFollower, either a *TipAdvanced or a *Reorg.
+type HeaderEvent interface {
+	isHeaderEvent()
+}
+
+// TipAdvanced reports new blocks extending the current tip, in ascending order.
+type TipAdvanced struct {
+	Connected []ChainHeader
+}
+
+// Reorg reports blocks disconnected from the chain above ForkHeight, the last
+// block shared by the old and new chains, and the blocks connected instead.
+// Reorgs deeper than the kept headers are reported from the oldest kept header.
+type Reorg struct {
+	ForkHeight   int64
+	Disconnected []ChainHeader
+	Connected    []ChainHeader
+}
+
+func (*TipAdvanced) isHeaderEvent() {}
+
+func (*Reorg) isHeaderEvent() {}
+
+// HeaderFollower follows the chain tip through header notifications, telling
+// apart new blocks from chain reorganizations.
+type HeaderFollower struct {
+	client  *Client
+	depth   int
+	headers []ChainHeader
+	events  chan HeaderEvent
+
+	lock sync.RWMutex
+}
+
+// FollowHeaders subscribes to header notifications and emits typed events on
+// the returned channel, closed once ctx is done or the client is shut down.
+// depth is the number of recent headers kept to find fork points.
+func (s *Client) FollowHeaders(
+	ctx context.Context,
+	depth int,
+) (*HeaderFollower, <-chan HeaderEvent, error) {
+	if depth <= 0 {
+		depth = defaultFollowDepth
+	}
+
+	notifs, err := s.SubscribeHeaders(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	first := <-notifs
+	if first == nil {
+		return nil, nil, errors.New("empty chain tip")
+	}
+
+	header, err := ParseBlockHeader(first.Hex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := &HeaderFollower{
+		client:  s,
+		depth:   depth,
+		headers: []ChainHeader{{Height: first.Height, Header: header}},
+		events:  make(chan HeaderEvent, 16),
+	}
+
+	go func() {
+		defer close(f.events)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.quit:
+				return
+			case notif := <-notifs:
+				event, err := f.handle(ctx, notif)
+				if err != nil {
+					s.logger.Errorf(
+						"Follow header %d failed: %v",
+						notif.Height,
+						err,
+					)
+					continue
+				}
+				if event == nil {
+					continue
+				}
+
+				select {
+				case f.events <- event:
+				case <-ctx.Done():
+					return
+				case <-s.quit:
+					return
+				}
+			}
+		}
+	}()
+
+	return f, f.events, nil
+}
+
+// Tip returns the current chain tip.
+func (f *HeaderFollower) Tip() ChainHeader {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.headers[len(f.headers)-1]
+}
+
+// Header returns the kept header at height.
+func (f *HeaderFollower) Header(height int64) (ChainHeader, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.header(height)
+}
+
+func (f *HeaderFollower) header(height int64) (ChainHeader, bool) {
+	i := height - f.headers[0].Height
+	if i < 0 || i >= int64(len(f.headers)) {
+		return ChainHeader{}, false
+	}
+	return f.headers[i], true
+}
+
+// handle updates the kept headers with a notified tip and returns the
+// resulting event, nil if the tip did not change.
+func (f *HeaderFollower) handle(
+	ctx context.Context,
+	notif *SubscribeHeadersResult,
+) (HeaderEvent, error) {
+	header, err := ParseBlockHeader(notif.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	tip := f.headers[len(f.headers)-1]
+	if notif.Height == tip.Height &&
+		header.BlockHash() == tip.Header.BlockHash() {
+		return nil, nil
+	}
+
+	// Fetch the blocks missed between the tip and the notified one.
+	var connected []ChainHeader
+	if notif.Height > tip.Height+1 {
+		connected, err = f.fetchHeaders(ctx, tip.Height+1, notif.Height-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	connected = append(
+		connected,
+		ChainHeader{Height: notif.Height, Header: header},
+	)
+
+	if connected[0].Height == tip.Height+1 &&
+		connected[0].Header.PrevBlock == tip.Header.BlockHash() {
+		err = checkLinked(connected)
+		if err != nil {
+			return nil, err
+		}
+
+		f.connect(connected)
+		return &TipAdvanced{Connected: connected}, nil
+	}
+
+	// Walk back until the new chain connects to a kept header.
+	oldest := f.headers[0].Height
+	for {
+		first := connected[0]
+		if first.Height <= oldest {
+			break
+		}
+
+		kept, ok := f.header(first.Height - 1)
+		if ok && first.Header.PrevBlock == kept.Header.BlockHash() {
+			break
+		}
+
+		res, err := f.client.GetBlockHeader(ctx, uint64(first.Height-1))
+		if err != nil {
+			return nil, err
+		}
+		prev, err := ParseBlockHeader(res.Header)
+		if err != nil {
+			return nil, err
+		}
+
+		connected = append(
+			[]ChainHeader{{Height: first.Height - 1, Header: prev}},
+			connected...,
+		)
+	}
+
+	err = checkLinked(connected)
+	if err != nil {
+		return nil, err
+	}
+
+	forkHeight := connected[0].Height - 1
+	var disconnected []ChainHeader
+	for _, h := range f.headers {
+		if h.Height > forkHeight {
+			disconnected = append(disconnected, h)
+		}
+	}
+
+	f.connect(connected)
+	return &Reorg{
+		ForkHeight:   forkHeight,
+		Disconnected: disconnected,
+		Connected:    connected,
+	}, nil
+}
+
+// checkLinked checks that every header builds on the previous one, which may
+// not hold if the chain changed again while the headers were fetched.
+func checkLinked(headers []ChainHeader) error {
+	for i := 1; i < len(headers); i++ {
+		if headers[i].Header.PrevBlock != headers[i-1].Header.BlockHash() {
+			return fmt.Errorf(
+				"%w: header %d",
+				ErrHeaderPrevHash,
+				headers[i].Height,
+			)
+		}
+	}
+	return nil
+}
+
+// fetchHeaders gets the headers from start to end included.
+func (f *HeaderFollower) fetchHeaders(
+	ctx context.Context,
+	start, end int64,
+) ([]ChainHeader, error) {
+	var result []ChainHeader
+	for start <= end {
+		count := end - start + 1
+		if count > headersChunkSize {
+			count = headersChunkSize
+		}
+
+		res, err := f.client.GetBlockHeaders(
+			ctx,
+			uint64(start),
+			uint64(count),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		headers, err := ParseBlockHeaders(res.Headers)
+		if err != nil {
+			return nil, err
+		}
+		if len(headers) == 0 {
+			break
+		}
+
+		for _, header := range headers {
+			result = append(
+				result,
+				ChainHeader{Height: start, Header: header},
+			)
+			start++
+		}
+	}
+
+	return result, nil
+}
+
+// connect replaces the kept headers from the first connected height and
+// trims the oldest ones beyond depth.
+func (f *HeaderFollower) connect(connected []ChainHeader) {
+	keep := 0
+	for keep < len(f.headers) &&
+		f.headers[keep].Height < connected[0].Height {
+		keep++
+	}
+
+	f.headers = append(f.headers[:keep:keep], connected...)
+	if len(f.headers) > f.depth {
+		f.headers = f.headers[len(f.headers)-f.depth:]
+	}
+}
@@ -0,0 +1,215 @@
+package electrum
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// followedChain is a chain of headers served by a fakeServer to a
+// HeaderFollower.
+type followedChain struct {
+	t       *testing.T
+	server  *fakeServer
+	headers []*wire.BlockHeader
+}
+
+func newFollowedChain(t *testing.T, height int) *followedChain {
+	_, genesis := newTestParams("follow", 2016, 0x207fffff)
+
+	c := &followedChain{
+		t:       t,
+		server:  newFakeServer(),
+		headers: []*wire.BlockHeader{genesis},
+	}
+	serveHeaders(c.server, &c.headers)
+	c.extend(height, 0)
+
+	return c
+}
+
+// extend mines headers of fork up to tip.
+func (c *followedChain) extend(tip int, fork byte) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+
+	bits := c.headers[0].Bits
+	for len(c.headers) <= tip {
+		c.headers = append(
+			c.headers,
+			nextHeader(c.headers[len(c.headers)-1], bits, time.Minute, fork),
+		)
+	}
+}
+
+// reorg replaces the headers from height with ones of fork up to tip, and
+// notifies the new tip.
+func (c *followedChain) reorg(height, tip int, fork byte) {
+	c.server.lock.Lock()
+	c.headers = c.headers[:height:height]
+	c.server.lock.Unlock()
+
+	c.extend(tip, fork)
+	c.notify(tip)
+}
+
+// notify pushes the header at height as the new tip.
+func (c *followedChain) notify(height int) {
+	c.server.push(
+		"blockchain.headers.subscribe",
+		&SubscribeHeadersResult{
+			Height: int64(height),
+			Hex:    c.hex(height),
+		},
+	)
+}
+
+func (c *followedChain) hex(height int) string {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+
+	b, err := serializeHeaders(c.headers[height : height+1])
+	require.NoError(c.t, err)
+	return hex.EncodeToString(b)
+}
+
+func (c *followedChain) header(height int) ChainHeader {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	return ChainHeader{Height: int64(height), Header: c.headers[height]}
+}
+
+func (c *followedChain) headersFrom(start, end int) []ChainHeader {
+	var headers []ChainHeader
+	for height := start; height <= end; height++ {
+		headers = append(headers, c.header(height))
+	}
+	return headers
+}
+
+func nextEvent(t *testing.T, events <-chan HeaderEvent) HeaderEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no header event")
+		return nil
+	}
+}
+
+func TestFollowHeaders(t *testing.T) {
+	chain := newFollowedChain(t, 10)
+	client := newFakeClient(t, chain.server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	follower, events, err := client.FollowHeaders(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, chain.header(10), follower.Tip())
+
+	// The tip advances by one block, then by two blocks with one missed.
+	chain.extend(13, 0)
+	chain.notify(11)
+	assert.Equal(
+		t,
+		&TipAdvanced{Connected: chain.headersFrom(11, 11)},
+		nextEvent(t, events),
+	)
+
+	chain.notify(13)
+	assert.Equal(
+		t,
+		&TipAdvanced{Connected: chain.headersFrom(12, 13)},
+		nextEvent(t, events),
+	)
+
+	// The tip block is replaced.
+	old := chain.headersFrom(13, 13)
+	chain.reorg(13, 13, 1)
+	assert.Equal(
+		t,
+		&Reorg{
+			ForkHeight:   12,
+			Disconnected: old,
+			Connected:    chain.headersFrom(13, 13),
+		},
+		nextEvent(t, events),
+	)
+
+	// A longer fork is walked back to the last shared block.
+	old = chain.headersFrom(11, 13)
+	chain.reorg(11, 14, 2)
+	assert.Equal(
+		t,
+		&Reorg{
+			ForkHeight:   10,
+			Disconnected: old,
+			Connected:    chain.headersFrom(11, 14),
+		},
+		nextEvent(t, events),
+	)
+	assert.Equal(t, chain.header(14), follower.Tip())
+
+	// A fork deeper than the 5 kept headers is reported from the oldest.
+	old = chain.headersFrom(10, 14)
+	chain.reorg(8, 15, 3)
+	assert.Equal(
+		t,
+		&Reorg{
+			ForkHeight:   9,
+			Disconnected: old,
+			Connected:    chain.headersFrom(10, 15),
+		},
+		nextEvent(t, events),
+	)
+
+	_, ok := follower.Header(10)
+	assert.False(t, ok)
+	header, ok := follower.Header(11)
+	require.True(t, ok)
+	assert.Equal(t, chain.header(11), header)
+
+	// The same tip notified again is no event.
+	chain.notify(15)
+
+	cancel()
+	select {
+	case event, ok := <-events:
+		assert.False(t, ok, "unexpected event %v", event)
+	case <-time.After(time.Second):
+		t.Fatal("events not closed")
+	}
+}
+
+func TestFollowHeadersShutdown(t *testing.T) {
+	chain := newFollowedChain(t, 1)
+	client := newFakeClient(t, chain.server)
+
+	_, events, err := client.FollowHeaders(context.Background(), 0)
+	require.NoError(t, err)
+
+	client.Shutdown()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("events not closed")
+	}
+}
+
+func TestFollowHeadersEmptyTip(t *testing.T) {
+	server := newFakeServer()
+	server.result("blockchain.headers.subscribe", nil)
+	client := newFakeClient(t, server)
+
+	_, _, err := client.FollowHeaders(context.Background(), 0)
+	assert.Error(t, err)
+}
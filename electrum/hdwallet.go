@@ -0,0 +1,369 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// defaultGapLimit is the number of consecutive unused addresses after
+	// which a chain is considered fully scanned, as in BIP44.
+	defaultGapLimit = 20
+
+	// ReceiveChain is the chain index of receive addresses.
+	ReceiveChain uint32 = 0
+	// ChangeChain is the chain index of change addresses.
+	ChangeChain uint32 = 1
+)
+
+// AddressType is the kind of addresses derived from an account extended key.
+type AddressType int
+
+const (
+	// AddressTypeAuto picks the address type from the extended key version:
+	// P2SH-P2WPKH for ypub, P2WPKH for zpub and P2PKH otherwise.
+	AddressTypeAuto AddressType = iota
+	// AddressTypeP2PKH derives legacy addresses, as in BIP44.
+	AddressTypeP2PKH
+	// AddressTypeP2SHP2WPKH derives nested segwit addresses, as in BIP49.
+	AddressTypeP2SHP2WPKH
+	// AddressTypeP2WPKH derives native segwit addresses, as in BIP84.
+	AddressTypeP2WPKH
+	// AddressTypeP2TR derives taproot key path addresses, as in BIP86.
+	AddressTypeP2TR
+)
+
+var (
+	// ErrInvalidGapLimit throws an error if the gap limit is not positive.
+	ErrInvalidGapLimit = errors.New("gap limit must be positive")
+
+	// ypub and upub versions identify BIP49 keys, zpub and vpub BIP84 keys.
+	ypubVersions = [][]byte{{0x04, 0x9d, 0x7c, 0xb2}, {0x04, 0x4a, 0x52, 0x62}}
+	zpubVersions = [][]byte{{0x04, 0xb2, 0x47, 0x46}, {0x04, 0x5f, 0x1c, 0xf6}}
+)
+
+// HDScanner discovers the used addresses of a watch-only account from its
+// extended public key.
+type HDScanner struct {
+	client       *Client
+	account      *hdkeychain.ExtendedKey
+	addressType  AddressType
+	gapLimit     int
+	subscription *ScripthashSubscription
+}
+
+// HDScannerOption configures a HDScanner.
+type HDScannerOption func(*HDScanner)
+
+// WithGapLimit sets the number of consecutive unused addresses ending a
+// chain scan. Defaults to 20.
+func WithGapLimit(gapLimit int) HDScannerOption {
+	return func(sc *HDScanner) {
+		sc.gapLimit = gapLimit
+	}
+}
+
+// WithAddressType overrides the address type inferred from the extended key
+// version, e.g. AddressTypeP2TR for BIP86 accounts exported as xpub.
+func WithAddressType(addressType AddressType) HDScannerOption {
+	return func(sc *HDScanner) {
+		sc.addressType = addressType
+	}
+}
+
+// WithScanSubscription subscribes every scanned address, used ones and those
+// of the gap, to sub.
+func WithScanSubscription(sub *ScripthashSubscription) HDScannerOption {
+	return func(sc *HDScanner) {
+		sc.subscription = sub
+	}
+}
+
+// HDAddress is an address derived from an account extended key.
+type HDAddress struct {
+	Chain      uint32              `json:"chain"`
+	Index      uint32              `json:"index"`
+	Address    string              `json:"address"`
+	ScriptHash string              `json:"scripthash"`
+	History    []*GetMempoolResult `json:"history,omitempty"`
+	Balance    GetBalanceResult    `json:"balance"`
+}
+
+// HDChainResult is the scan result of a receive or change chain.
+type HDChainResult struct {
+	Used []*HDAddress `json:"used"`
+	// NextIndex is the index following the last used address.
+	NextIndex uint32 `json:"next_index"`
+}
+
+// HDScanResult is the scan result of an account.
+type HDScanResult struct {
+	Receive HDChainResult    `json:"receive"`
+	Change  HDChainResult    `json:"change"`
+	Balance GetBalanceResult `json:"balance"`
+}
+
+// NewHDScanner creates a scanner for an account level extended key (xpub,
+// ypub, zpub or their testnet versions). Addresses are encoded for the client
+// network. Private keys are neutered.
+func (s *Client) NewHDScanner(
+	extendedKey string,
+	opts ...HDScannerOption,
+) (*HDScanner, error) {
+	account, err := hdkeychain.NewKeyFromString(extendedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err = account.Neuter()
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &HDScanner{
+		client:   s,
+		account:  account,
+		gapLimit: defaultGapLimit,
+	}
+
+	for _, opt := range opts {
+		opt(sc)
+	}
+
+	if sc.gapLimit <= 0 {
+		return nil, ErrInvalidGapLimit
+	}
+
+	if sc.addressType == AddressTypeAuto {
+		sc.addressType = addressTypeOf(account.Version())
+	}
+
+	return sc, nil
+}
+
+func addressTypeOf(version []byte) AddressType {
+	for _, v := range ypubVersions {
+		if bytes.Equal(version, v) {
+			return AddressTypeP2SHP2WPKH
+		}
+	}
+	for _, v := range zpubVersions {
+		if bytes.Equal(version, v) {
+			return AddressTypeP2WPKH
+		}
+	}
+	return AddressTypeP2PKH
+}
+
+// DeriveAddress derives the address at index of a chain, ReceiveChain or
+// ChangeChain.
+func (sc *HDScanner) DeriveAddress(chain, index uint32) (*HDAddress, error) {
+	chainKey, err := sc.account.Derive(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.deriveAddress(chainKey, chain, index)
+}
+
+func (sc *HDScanner) deriveAddress(
+	chainKey *hdkeychain.ExtendedKey,
+	chain, index uint32,
+) (*HDAddress, error) {
+	key, err := chainKey.Derive(index)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	params := sc.client.Network()
+	pubKeyHash := btcutil.Hash160(pubKey.SerializeCompressed())
+
+	var address btcutil.Address
+	switch sc.addressType {
+	case AddressTypeP2SHP2WPKH:
+		witnessScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_0).
+			AddData(pubKeyHash).
+			Script()
+		if err != nil {
+			return nil, err
+		}
+		address, err = btcutil.NewAddressScriptHash(witnessScript, params)
+		if err != nil {
+			return nil, err
+		}
+	case AddressTypeP2WPKH:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+	case AddressTypeP2TR:
+		outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+		address, err = btcutil.NewAddressTaproot(
+			schnorr.SerializePubKey(outputKey),
+			params,
+		)
+	default:
+		address, err = btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HDAddress{
+		Chain:      chain,
+		Index:      index,
+		Address:    address.EncodeAddress(),
+		ScriptHash: ScriptToElectrumScriptHash(script),
+	}, nil
+}
+
+// Scan scans the receive and change chains until gap limit consecutive
+// addresses have no history, and returns the used addresses with their
+// history and balance.
+func (sc *HDScanner) Scan(ctx context.Context) (*HDScanResult, error) {
+	receive, err := sc.scanChain(ctx, ReceiveChain)
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := sc.scanChain(ctx, ChangeChain)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &HDScanResult{Receive: *receive, Change: *change}
+	for _, used := range [][]*HDAddress{receive.Used, change.Used} {
+		for _, addr := range used {
+			res.Balance.Confirmed += addr.Balance.Confirmed
+			res.Balance.Unconfirmed += addr.Balance.Unconfirmed
+		}
+	}
+
+	return res, nil
+}
+
+func (sc *HDScanner) scanChain(
+	ctx context.Context,
+	chain uint32,
+) (*HDChainResult, error) {
+	chainKey, err := sc.account.Derive(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	windowSize := sc.gapLimit
+	if windowSize > maxBatchSize {
+		windowSize = maxBatchSize
+	}
+
+	res := &HDChainResult{}
+	var scanned []*HDAddress
+	var index uint32
+	for index < res.NextIndex+uint32(sc.gapLimit) {
+		batch := sc.client.NewBatch()
+		addresses := make([]*HDAddress, 0, windowSize)
+		calls := make([]*BatchCall[[]*GetMempoolResult], 0, windowSize)
+		for i := 0; i < windowSize; i++ {
+			addr, err := sc.deriveAddress(chainKey, chain, index)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, addr)
+			calls = append(calls, batch.GetHistory(addr.ScriptHash))
+			index++
+		}
+
+		err := batch.Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, call := range calls {
+			addr := addresses[i]
+			// The window may reach past the gap limit.
+			if addr.Index >= res.NextIndex+uint32(sc.gapLimit) {
+				break
+			}
+
+			history, err := call.Result()
+			if err != nil {
+				return nil, err
+			}
+
+			if len(history) > 0 {
+				addr.History = history
+				res.Used = append(res.Used, addr)
+				res.NextIndex = addr.Index + 1
+			}
+			scanned = append(scanned, addr)
+		}
+	}
+
+	err = sc.fetchBalances(ctx, res.Used)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc.subscription != nil {
+		last := res.NextIndex + uint32(sc.gapLimit)
+		for _, addr := range scanned {
+			if addr.Index >= last {
+				break
+			}
+			err = sc.subscription.Add(ctx, addr.ScriptHash, addr.Address)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// fetchBalances gets the balance of addresses in batches.
+func (sc *HDScanner) fetchBalances(
+	ctx context.Context,
+	addresses []*HDAddress,
+) error {
+	for start := 0; start < len(addresses); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		batch := sc.client.NewBatch()
+		calls := make([]*BatchCall[GetBalanceResult], 0, end-start)
+		for _, addr := range addresses[start:end] {
+			calls = append(calls, batch.GetBalance(addr.ScriptHash))
+		}
+
+		err := batch.Send(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i, call := range calls {
+			balance, err := call.Result()
+			if err != nil {
+				return err
+			}
+			addresses[start+i].Balance = balance
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package electrum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHDScannerDeriveAddress(t *testing.T) {
+	tests := []struct {
+		key         string
+		addressType AddressType
+		chain       uint32
+		index       uint32
+		wantAddress string
+	}{
+		{
+			// BIP49 test vector.
+			key:         "ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP",
+			chain:       ReceiveChain,
+			index:       0,
+			wantAddress: "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf",
+		},
+		{
+			// BIP84 test vectors.
+			key:         "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+			chain:       ReceiveChain,
+			index:       1,
+			wantAddress: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+		},
+		{
+			key:         "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+			chain:       ChangeChain,
+			index:       0,
+			wantAddress: "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el",
+		},
+		{
+			// BIP86 test vector.
+			key:         "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ",
+			addressType: AddressTypeP2TR,
+			chain:       ReceiveChain,
+			index:       0,
+			wantAddress: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		},
+	}
+
+	client := &Client{}
+	for _, tc := range tests {
+		sc, err := client.NewHDScanner(tc.key, WithAddressType(tc.addressType))
+		require.NoError(t, err)
+
+		addr, err := sc.DeriveAddress(tc.chain, tc.index)
+		require.NoError(t, err)
+		assert.Equal(t, tc.wantAddress, addr.Address)
+
+		scriptHash, err := AddressToElectrumScriptHash(tc.wantAddress)
+		require.NoError(t, err)
+		assert.Equal(t, scriptHash, addr.ScriptHash)
+	}
+
+	_, err := client.NewHDScanner(tests[0].key, WithGapLimit(0))
+	assert.ErrorIs(t, err, ErrInvalidGapLimit)
+}
+
+func TestHDScannerScan(t *testing.T) {
+	const zpub = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+
+	server := newFakeServer()
+	client := newFakeClient(t, server)
+
+	sub, _ := client.SubscribeScripthash()
+	sc, err := client.NewHDScanner(
+		zpub,
+		WithGapLimit(5),
+		WithScanSubscription(sub),
+	)
+	require.NoError(t, err)
+
+	// With a gap limit of 5, receive addresses 0 and 5 are found but not 11,
+	// after 5 unused ones. Change address 4 is found but not 10.
+	used := map[string]uint32{}
+	for chain, indexes := range map[uint32][]uint32{
+		ReceiveChain: {0, 5, 11},
+		ChangeChain:  {4, 10},
+	} {
+		for _, index := range indexes {
+			addr, err := sc.DeriveAddress(chain, index)
+			require.NoError(t, err)
+			used[addr.ScriptHash] = index
+		}
+	}
+
+	server.on(
+		"blockchain.scripthash.get_history",
+		func(params []interface{}) (interface{}, error) {
+			index, ok := used[params[0].(string)]
+			if !ok {
+				return []*GetMempoolResult{}, nil
+			}
+			return []*GetMempoolResult{
+				{Hash: "txid", Height: int64(100 + index)},
+			}, nil
+		},
+	)
+	server.result(
+		"blockchain.scripthash.get_balance",
+		map[string]int64{"confirmed": 1000, "unconfirmed": 10},
+	)
+	server.result("blockchain.scripthash.subscribe", nil)
+
+	res, err := sc.Scan(context.Background())
+	require.NoError(t, err)
+
+	indexes := func(addresses []*HDAddress) []uint32 {
+		var indexes []uint32
+		for _, addr := range addresses {
+			indexes = append(indexes, addr.Index)
+		}
+		return indexes
+	}
+	assert.Equal(t, []uint32{0, 5}, indexes(res.Receive.Used))
+	assert.Equal(t, uint32(6), res.Receive.NextIndex)
+	assert.Equal(t, []uint32{4}, indexes(res.Change.Used))
+	assert.Equal(t, uint32(5), res.Change.NextIndex)
+
+	assert.Equal(t, int64(105), res.Receive.Used[1].History[0].Height)
+	assert.Equal(t, btcutil.Amount(3000), res.Balance.Confirmed)
+	assert.Equal(t, btcutil.Amount(30), res.Balance.Unconfirmed)
+
+	// Addresses up to the gap limit past the last used one are subscribed.
+	assert.Equal(t, 11+10, server.calls("blockchain.scripthash.subscribe"))
+}
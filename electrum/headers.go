@@ -0,0 +1,372 @@
+package electrum
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// headersChunkSize is the number of headers of a difficulty period, also
+	// the maximum the server returns at once.
+	headersChunkSize = 2016
+
+	// maxReorgDepth bounds how far back a diverging chain is looked for.
+	maxReorgDepth = 144
+
+	// maxTimewarp is how far in the past of the previous header the first
+	// header of a difficulty period may be, per BIP94.
+	maxTimewarp = 600 * time.Second
+)
+
+var (
+	// ErrHeaderPrevHash throws an error if a header does not connect to the previous one.
+	ErrHeaderPrevHash = errors.New("header does not connect to previous header")
+
+	// ErrHeaderPoW throws an error if a header hash does not meet its target.
+	ErrHeaderPoW = errors.New("header hash does not meet proof of work target")
+
+	// ErrHeaderDifficulty throws an error if a header target is not the expected one.
+	ErrHeaderDifficulty = errors.New("header difficulty does not match expected difficulty")
+
+	// ErrHeaderTimewarp throws an error if the first header of a difficulty period
+	// is too far in the past of the previous one, on networks enforcing BIP94.
+	ErrHeaderTimewarp = errors.New("header timestamp violates timewarp rule")
+
+	// ErrGenesisMismatch throws an error if the first header is not the network genesis.
+	ErrGenesisMismatch = errors.New("genesis header does not match network")
+
+	// ErrReorgTooDeep throws an error if the local chain diverges from the remote
+	// server deeper than maxReorgDepth.
+	ErrReorgTooDeep = errors.New("chain reorganization is too deep")
+)
+
+// HeaderChain is a local chain of block headers, each validated for linkage,
+// proof of work and difficulty retargeting before being stored.
+type HeaderChain struct {
+	params *chaincfg.Params
+	store  HeaderStore
+
+	lock sync.Mutex
+}
+
+// NewHeaderChain creates a header chain for the network params, persisting
+// headers in store.
+func NewHeaderChain(params *chaincfg.Params, store HeaderStore) *HeaderChain {
+	return &HeaderChain{
+		params: params,
+		store:  store,
+	}
+}
+
+// Height returns the height of the chain tip, -1 if the chain is empty.
+func (c *HeaderChain) Height() int64 {
+	return c.store.Height()
+}
+
+// Header returns the header at height.
+func (c *HeaderChain) Header(height int64) (*wire.BlockHeader, error) {
+	return c.store.Get(height)
+}
+
+// Confirmations returns the number of confirmations of a block at height.
+func (c *HeaderChain) Confirmations(height int64) int64 {
+	tip := c.Height()
+	if height < 0 || height > tip {
+		return 0
+	}
+	return tip - height + 1
+}
+
+// Close closes the underlying store.
+func (c *HeaderChain) Close() error {
+	return c.store.Close()
+}
+
+// Connect validates headers following the chain tip and stores them. Either
+// all headers are stored or none.
+func (c *HeaderChain) Connect(headers []*wire.BlockHeader) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.connect(headers)
+}
+
+func (c *HeaderChain) connect(headers []*wire.BlockHeader) error {
+	tip := c.store.Height()
+
+	var prev *wire.BlockHeader
+	if tip >= 0 {
+		var err error
+		prev, err = c.store.Get(tip)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Lookups may reach headers of this batch not stored yet.
+	get := func(height int64) (*wire.BlockHeader, error) {
+		if height > tip {
+			return headers[height-tip-1], nil
+		}
+		return c.store.Get(height)
+	}
+
+	for i, header := range headers {
+		height := tip + 1 + int64(i)
+		err := c.checkHeader(height, header, prev, get)
+		if err != nil {
+			return fmt.Errorf("header %d: %w", height, err)
+		}
+		prev = header
+	}
+
+	return c.store.Append(headers...)
+}
+
+func (c *HeaderChain) checkHeader(
+	height int64,
+	header, prev *wire.BlockHeader,
+	get func(height int64) (*wire.BlockHeader, error),
+) error {
+	if height == 0 {
+		if header.BlockHash() != *c.params.GenesisHash {
+			return ErrGenesisMismatch
+		}
+		return nil
+	}
+
+	if header.PrevBlock != prev.BlockHash() {
+		return ErrHeaderPrevHash
+	}
+
+	if c.params.Net == testNet4 && c.isRetarget(height) &&
+		header.Timestamp.Before(prev.Timestamp.Add(-maxTimewarp)) {
+		return ErrHeaderTimewarp
+	}
+
+	bits, err := c.expectedBits(height, header, prev, get)
+	if err != nil {
+		return err
+	}
+	if header.Bits != bits {
+		return ErrHeaderDifficulty
+	}
+
+	return checkProofOfWork(header, c.params.PowLimit)
+}
+
+// checkProofOfWork checks that the header hash is below its target, itself
+// below the network limit.
+func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int) error {
+	target := blockchain.CompactToBig(header.Bits)
+	if target.Sign() <= 0 || target.Cmp(powLimit) > 0 {
+		return ErrHeaderPoW
+	}
+
+	hash := header.BlockHash()
+	if blockchain.HashToBig(&hash).Cmp(target) > 0 {
+		return ErrHeaderPoW
+	}
+
+	return nil
+}
+
+// expectedBits computes the target a header at height must have, following
+// the rules of btcd calcNextRequiredDifficulty.
+func (c *HeaderChain) expectedBits(
+	height int64,
+	header, prev *wire.BlockHeader,
+	get func(height int64) (*wire.BlockHeader, error),
+) (uint32, error) {
+	// Regression test networks never retarget.
+	if powNoRetargeting(c.params) {
+		return prev.Bits, nil
+	}
+
+	blocksPerRetarget := c.blocksPerRetarget()
+
+	if !c.isRetarget(height) {
+		if !c.params.ReduceMinDifficulty {
+			return prev.Bits, nil
+		}
+
+		// Networks allowing minimum difficulty blocks after a delay fall back
+		// to the last regular difficulty of the period otherwise.
+		reductionTime := int64(c.params.MinDiffReductionTime / time.Second)
+		if header.Timestamp.Unix() > prev.Timestamp.Unix()+reductionTime {
+			return c.params.PowLimitBits, nil
+		}
+
+		last := prev
+		for h := height - 1; h%blocksPerRetarget != 0 &&
+			last.Bits == c.params.PowLimitBits; h-- {
+			var err error
+			last, err = get(h - 1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return last.Bits, nil
+	}
+
+	first, err := get(height - blocksPerRetarget)
+	if err != nil {
+		return 0, err
+	}
+
+	targetTimespan := int64(c.params.TargetTimespan / time.Second)
+	adjustmentFactor := c.params.RetargetAdjustmentFactor
+	minTimespan := targetTimespan / adjustmentFactor
+	maxTimespan := targetTimespan * adjustmentFactor
+
+	actualTimespan := prev.Timestamp.Unix() - first.Timestamp.Unix()
+	if actualTimespan < minTimespan {
+		actualTimespan = minTimespan
+	} else if actualTimespan > maxTimespan {
+		actualTimespan = maxTimespan
+	}
+
+	// BIP94 networks retarget from the first header of the period, not
+	// from a possibly minimum difficulty last one.
+	lastBits := prev.Bits
+	if c.params.Net == testNet4 {
+		lastBits = first.Bits
+	}
+
+	newTarget := new(big.Int).Mul(
+		blockchain.CompactToBig(lastBits),
+		big.NewInt(actualTimespan),
+	)
+	newTarget.Div(newTarget, big.NewInt(targetTimespan))
+	if newTarget.Cmp(c.params.PowLimit) > 0 {
+		newTarget.Set(c.params.PowLimit)
+	}
+
+	return blockchain.BigToCompact(newTarget), nil
+}
+
+func (c *HeaderChain) blocksPerRetarget() int64 {
+	return int64(c.params.TargetTimespan / c.params.TargetTimePerBlock)
+}
+
+// isRetarget reports whether the header at height starts a difficulty period.
+func (c *HeaderChain) isRetarget(height int64) bool {
+	return height%c.blocksPerRetarget() == 0
+}
+
+// ParseBlockHeaders decodes hex encoded concatenated 80-byte block headers, as
+// returned by GetBlockHeaders.
+func ParseBlockHeaders(headersHex string) ([]*wire.BlockHeader, error) {
+	b, err := hex.DecodeString(headersHex)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b)%blockHeaderSize != 0 {
+		return nil, fmt.Errorf("invalid headers length %d", len(b))
+	}
+
+	headers := make([]*wire.BlockHeader, 0, len(b)/blockHeaderSize)
+	for offset := 0; offset < len(b); offset += blockHeaderSize {
+		header, err := deserializeHeader(b[offset : offset+blockHeaderSize])
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+
+	return headers, nil
+}
+
+// Sync downloads the headers the chain is missing from the remote server in
+// chunks of 2016 headers, validates and stores them. If the remote chain
+// diverged from the local one, the local headers past the fork are dropped.
+func (c *HeaderChain) Sync(ctx context.Context, client *Client) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for {
+		start := c.store.Height() + 1
+
+		res, err := client.GetBlockHeaders(
+			ctx,
+			uint64(start),
+			headersChunkSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		headers, err := ParseBlockHeaders(res.Headers)
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return nil
+		}
+
+		if start > 0 {
+			tip, err := c.store.Get(start - 1)
+			if err != nil {
+				return err
+			}
+
+			if headers[0].PrevBlock != tip.BlockHash() {
+				err = c.rewind(ctx, client)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		err = c.connect(headers)
+		if err != nil {
+			return err
+		}
+
+		max := headersChunkSize
+		if res.Max > 0 && int(res.Max) < max {
+			max = int(res.Max)
+		}
+		if len(headers) < max {
+			return nil
+		}
+	}
+}
+
+// rewind drops the local headers after the last one the remote server agrees on.
+func (c *HeaderChain) rewind(ctx context.Context, client *Client) error {
+	tip := c.store.Height()
+	for height := tip; height >= 0 && tip-height <= maxReorgDepth; height-- {
+		res, err := client.GetBlockHeader(ctx, uint64(height))
+		if err != nil {
+			return err
+		}
+
+		remote, err := ParseBlockHeader(res.Header)
+		if err != nil {
+			return err
+		}
+
+		local, err := c.store.Get(height)
+		if err != nil {
+			return err
+		}
+
+		if remote.BlockHash() == local.BlockHash() {
+			return c.store.Truncate(height)
+		}
+	}
+
+	return ErrReorgTooDeep
+}
@@ -0,0 +1,353 @@
+package electrum
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Blocks 1 and 2 of mainnet
+const (
+	testHeader1 = "010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000" +
+		"982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e" +
+		"61bc6649ffff001d01e36299"
+	testHeader2 = "010000004860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000" +
+		"d5fdcc541e25de1c7a5addedf24858b8bb665c9f36ef744ee42c316022c90f9b" +
+		"b0bc6649ffff001d08d2bd61"
+)
+
+// newTestParams returns params with an easy proof of work, retargeting every
+// blocksPerRetarget blocks, and their genesis header mined at genesisBits.
+// Networks are told apart by name, hashed into their genesis merkle root.
+func newTestParams(
+	name string,
+	blocksPerRetarget int64,
+	genesisBits uint32,
+) (*chaincfg.Params, *wire.BlockHeader) {
+	params := chaincfg.RegressionNetParams
+	params.Name = name
+	params.ReduceMinDifficulty = false
+	params.TargetTimespan = time.Duration(blocksPerRetarget) *
+		params.TargetTimePerBlock
+
+	genesis := mineHeader(&wire.BlockHeader{
+		Version:    1,
+		MerkleRoot: chainhash.HashH([]byte(name)),
+		Timestamp:  time.Unix(1600000000, 0),
+		Bits:       genesisBits,
+	})
+	hash := genesis.BlockHash()
+	params.GenesisHash = &hash
+
+	return &params, genesis
+}
+
+// mineHeader solves the proof of work of header.
+func mineHeader(header *wire.BlockHeader) *wire.BlockHeader {
+	for checkProofOfWork(header, blockchain.CompactToBig(header.Bits)) != nil {
+		header.Nonce++
+	}
+	return header
+}
+
+// nextHeader mines a header on top of prev, interval later. Forks are told
+// apart by their merkle root.
+func nextHeader(
+	prev *wire.BlockHeader,
+	bits uint32,
+	interval time.Duration,
+	fork byte,
+) *wire.BlockHeader {
+	return mineHeader(&wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  prev.BlockHash(),
+		MerkleRoot: chainhash.Hash{fork},
+		Timestamp:  prev.Timestamp.Add(interval),
+		Bits:       bits,
+	})
+}
+
+// serveHeaders answers header requests with the headers of chain, which
+// tests replace under the server lock.
+func serveHeaders(server *fakeServer, chain *[]*wire.BlockHeader) {
+	headersHex := func(start, end int) string {
+		if end > len(*chain) {
+			end = len(*chain)
+		}
+		if start > end {
+			start = end
+		}
+		b, _ := serializeHeaders((*chain)[start:end])
+		return hex.EncodeToString(b)
+	}
+
+	server.on(
+		"blockchain.headers.subscribe",
+		func([]interface{}) (interface{}, error) {
+			tip := len(*chain) - 1
+			return &SubscribeHeadersResult{
+				Height: int64(tip),
+				Hex:    headersHex(tip, tip+1),
+			}, nil
+		},
+	)
+	server.on(
+		"blockchain.block.header",
+		func(params []interface{}) (interface{}, error) {
+			height := int(params[0].(float64))
+			return headersHex(height, height+1), nil
+		},
+	)
+	server.on(
+		"blockchain.block.headers",
+		func(params []interface{}) (interface{}, error) {
+			start := int(params[0].(float64))
+			end := start + int(params[1].(float64))
+			headers := headersHex(start, end)
+			return &GetBlockHeadersResult{
+				Count:   uint32(len(headers) / (2 * blockHeaderSize)),
+				Headers: headers,
+				Max:     headersChunkSize,
+			}, nil
+		},
+	)
+}
+
+func TestGetBlockHeaderForgedCheckpoint(t *testing.T) {
+	// A branch of made up hashes, with the root it leads to.
+	branch := []string{
+		chainhash.HashH([]byte("forged 1")).String(),
+		chainhash.HashH([]byte("forged 2")).String(),
+	}
+	header, err := ParseBlockHeader(testHeader2)
+	require.NoError(t, err)
+	root, err := ComputeMerkleRoot(header.BlockHash().String(), branch, 2)
+	require.NoError(t, err)
+
+	server := newFakeServer()
+	server.result("blockchain.block.header", &GetBlockHeaderResult{
+		Branch: branch,
+		Header: testHeader2,
+		Root:   root.String(),
+	})
+
+	// Without a known root, the server is only consistent with itself.
+	client := newFakeClient(t, server)
+	_, err = client.GetBlockHeader(context.Background(), 2, 2)
+	require.NoError(t, err)
+
+	// Root of the hashes of blocks 0 to 2
+	known := "6ce668e2ec49dae449c0d55de7b1c973696c7d4a47024dbcb26ee99411244ff6"
+	client = newFakeClient(
+		t,
+		server,
+		WithCheckpoints(map[uint64]string{2: known}),
+	)
+	_, err = client.GetBlockHeader(context.Background(), 2, 2)
+	assert.ErrorIs(t, err, ErrCheckpointMismatch)
+}
+
+func TestHeaderChainConnect(t *testing.T) {
+	headers, err := ParseBlockHeaders(testHeader1 + testHeader2)
+	require.NoError(t, err)
+	require.Len(t, headers, 2)
+
+	genesis := chaincfg.MainNetParams.GenesisBlock.Header
+
+	chain := NewHeaderChain(&chaincfg.MainNetParams, NewMemoryHeaderStore())
+	assert.ErrorIs(t, chain.Connect(headers), ErrGenesisMismatch)
+	assert.ErrorIs(
+		t,
+		chain.Connect([]*wire.BlockHeader{&genesis, headers[1]}),
+		ErrHeaderPrevHash,
+	)
+
+	tampered := *headers[1]
+	tampered.Nonce++
+	assert.ErrorIs(
+		t,
+		chain.Connect([]*wire.BlockHeader{&genesis, headers[0], &tampered}),
+		ErrHeaderPoW,
+	)
+	assert.Equal(t, int64(-1), chain.Height())
+
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{&genesis}))
+	require.NoError(t, chain.Connect(headers))
+	assert.Equal(t, int64(2), chain.Height())
+	assert.Equal(t, int64(2), chain.Confirmations(1))
+
+	header, err := chain.Header(2)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd",
+		header.BlockHash().String(),
+	)
+}
+
+func TestVerifyCheckpointBranch(t *testing.T) {
+	// Merkle root of the hashes of blocks 0 to 2
+	root := "6ce668e2ec49dae449c0d55de7b1c973696c7d4a47024dbcb26ee99411244ff6"
+	branch := []string{
+		"000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd",
+		"abdc2227d02d114b77be15085c1257709252a7a103f9ac0ab3c85d67e12bc0b8",
+	}
+
+	require.NoError(t, VerifyCheckpointBranch(testHeader2, 2, branch, root))
+	assert.ErrorIs(
+		t,
+		VerifyCheckpointBranch(testHeader1, 2, branch, root),
+		ErrCheckpointMismatch,
+	)
+
+	c := &Client{checkpoints: map[uint64]string{2: root}}
+	require.NoError(t, c.verifyCheckpoint(testHeader2, 2, 2, branch, root))
+
+	c.checkpoints[2] = branch[1]
+	assert.ErrorIs(
+		t,
+		c.verifyCheckpoint(testHeader2, 2, 2, branch, root),
+		ErrCheckpointMismatch,
+	)
+}
+
+func TestHeaderChainRetarget(t *testing.T) {
+	params, genesis := newTestParams("retarget", 4, 0x207fffff)
+	chain := NewHeaderChain(params, NewMemoryHeaderStore())
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{genesis}))
+
+	// Blocks four times faster than targeted.
+	prev := genesis
+	for i := 0; i < 3; i++ {
+		prev = nextHeader(prev, params.PowLimitBits, time.Minute, 0)
+		require.NoError(t, chain.Connect([]*wire.BlockHeader{prev}))
+	}
+
+	// The difficulty quadruples at the retarget boundary, the most allowed.
+	assert.ErrorIs(
+		t,
+		chain.Connect([]*wire.BlockHeader{
+			nextHeader(prev, params.PowLimitBits, time.Minute, 0),
+		}),
+		ErrHeaderDifficulty,
+	)
+
+	bits := blockchain.BigToCompact(
+		new(big.Int).Div(params.PowLimit, big.NewInt(4)),
+	)
+	prev = nextHeader(prev, bits, time.Minute, 0)
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{prev}))
+
+	// Until the next boundary, the difficulty stays.
+	assert.ErrorIs(
+		t,
+		chain.Connect([]*wire.BlockHeader{
+			nextHeader(prev, params.PowLimitBits, time.Minute, 0),
+		}),
+		ErrHeaderDifficulty,
+	)
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{
+		nextHeader(prev, bits, time.Minute, 0),
+	}))
+}
+
+func TestHeaderChainNoRetargeting(t *testing.T) {
+	params, genesis := newTestParams("noretarget", 4, 0x207fffff)
+	RegisterNoRetargeting(params)
+
+	chain := NewHeaderChain(params, NewMemoryHeaderStore())
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{genesis}))
+
+	prev := genesis
+	for i := 0; i < 8; i++ {
+		prev = nextHeader(prev, params.PowLimitBits, time.Minute, 0)
+		require.NoError(t, chain.Connect([]*wire.BlockHeader{prev}))
+	}
+}
+
+func TestHeaderChainMinDifficulty(t *testing.T) {
+	const bits = 0x1f7fffff
+
+	params, genesis := newTestParams("mindifficulty", 100, bits)
+	params.ReduceMinDifficulty = true
+	params.MinDiffReductionTime = 20 * time.Minute
+
+	chain := NewHeaderChain(params, NewMemoryHeaderStore())
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{genesis}))
+
+	// Minimum difficulty blocks are only allowed after 20 minutes.
+	assert.ErrorIs(
+		t,
+		chain.Connect([]*wire.BlockHeader{
+			nextHeader(genesis, params.PowLimitBits, 10*time.Minute, 0),
+		}),
+		ErrHeaderDifficulty,
+	)
+	block1 := nextHeader(genesis, bits, 10*time.Minute, 0)
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{block1}))
+
+	block2 := nextHeader(block1, params.PowLimitBits, 30*time.Minute, 0)
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{block2}))
+
+	// The next regular block has the last regular difficulty again.
+	assert.ErrorIs(
+		t,
+		chain.Connect([]*wire.BlockHeader{
+			nextHeader(block2, params.PowLimitBits, 10*time.Minute, 0),
+		}),
+		ErrHeaderDifficulty,
+	)
+	require.NoError(t, chain.Connect([]*wire.BlockHeader{
+		nextHeader(block2, bits, 10*time.Minute, 0),
+	}))
+	assert.Equal(t, int64(3), chain.Height())
+}
+
+func TestHeaderChainSyncReorg(t *testing.T) {
+	params, genesis := newTestParams("sync", 2016, 0x207fffff)
+
+	// The local chain has blocks 2 and 3 of a fork the remote server
+	// replaced with blocks 2 to 4 of another one.
+	local := []*wire.BlockHeader{genesis}
+	for i := 1; i <= 3; i++ {
+		local = append(
+			local,
+			nextHeader(local[i-1], params.PowLimitBits, time.Minute, 0),
+		)
+	}
+	remote := append([]*wire.BlockHeader{}, local[:2]...)
+	for i := 2; i <= 4; i++ {
+		remote = append(
+			remote,
+			nextHeader(remote[i-1], params.PowLimitBits, time.Minute, 1),
+		)
+	}
+
+	server := newFakeServer()
+	serveHeaders(server, &remote)
+	client := newFakeClient(t, server)
+
+	chain := NewHeaderChain(params, NewMemoryHeaderStore())
+	require.NoError(t, chain.Connect(local))
+
+	require.NoError(t, chain.Sync(context.Background(), client))
+	assert.Equal(t, int64(4), chain.Height())
+	for height, want := range remote {
+		header, err := chain.Header(int64(height))
+		require.NoError(t, err)
+		assert.Equal(t, want.BlockHash(), header.BlockHash())
+	}
+
+	// Blocks 3 and 2 of the local fork were compared before finding the
+	// common block 1.
+	assert.Equal(t, 3, server.calls("blockchain.block.header"))
+}
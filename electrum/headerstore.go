@@ -0,0 +1,215 @@
+package electrum
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// blockHeaderSize is the size of a serialized block header.
+const blockHeaderSize = 80
+
+var (
+	// ErrHeaderNotFound throws an error if a header is not in the store.
+	ErrHeaderNotFound = errors.New("header not found")
+)
+
+// HeaderStore persists block headers indexed by height, starting at genesis.
+type HeaderStore interface {
+	// Height returns the height of the last stored header, -1 if empty.
+	Height() int64
+	Get(height int64) (*wire.BlockHeader, error)
+	// Append stores headers right after the last stored header.
+	Append(headers ...*wire.BlockHeader) error
+	// Truncate removes every header above height.
+	Truncate(height int64) error
+	Close() error
+}
+
+func serializeHeaders(headers []*wire.BlockHeader) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(headers) * blockHeaderSize)
+	for _, header := range headers {
+		err := header.Serialize(&buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func deserializeHeader(b []byte) (*wire.BlockHeader, error) {
+	header := &wire.BlockHeader{}
+	err := header.Deserialize(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// FileHeaderStore stores raw headers back to back in a single file, like the
+// blockchain_headers file of Electrum.
+type FileHeaderStore struct {
+	file   *os.File
+	height int64
+	lock   sync.RWMutex
+}
+
+// NewFileHeaderStore opens or creates the headers file at path.
+func NewFileHeaderStore(path string) (*FileHeaderStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Drop a partially written header, if any.
+	count := info.Size() / blockHeaderSize
+	if info.Size()%blockHeaderSize != 0 {
+		err = file.Truncate(count * blockHeaderSize)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &FileHeaderStore{
+		file:   file,
+		height: count - 1,
+	}, nil
+}
+
+func (s *FileHeaderStore) Height() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.height
+}
+
+func (s *FileHeaderStore) Get(height int64) (*wire.BlockHeader, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if height < 0 || height > s.height {
+		return nil, ErrHeaderNotFound
+	}
+
+	b := make([]byte, blockHeaderSize)
+	_, err := s.file.ReadAt(b, height*blockHeaderSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return deserializeHeader(b)
+}
+
+func (s *FileHeaderStore) Append(headers ...*wire.BlockHeader) error {
+	b, err := serializeHeaders(headers)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err = s.file.WriteAt(b, (s.height+1)*blockHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	s.height += int64(len(headers))
+	return nil
+}
+
+func (s *FileHeaderStore) Truncate(height int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if height >= s.height {
+		return nil
+	}
+	if height < -1 {
+		height = -1
+	}
+
+	err := s.file.Truncate((height + 1) * blockHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	s.height = height
+	return nil
+}
+
+func (s *FileHeaderStore) Close() error {
+	return s.file.Close()
+}
+
+// MemoryHeaderStore keeps headers in memory only.
+type MemoryHeaderStore struct {
+	data []byte
+	lock sync.RWMutex
+}
+
+// NewMemoryHeaderStore creates an empty in-memory header store.
+func NewMemoryHeaderStore() *MemoryHeaderStore {
+	return &MemoryHeaderStore{}
+}
+
+func (s *MemoryHeaderStore) Height() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return int64(len(s.data)/blockHeaderSize) - 1
+}
+
+func (s *MemoryHeaderStore) Get(height int64) (*wire.BlockHeader, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	offset := height * blockHeaderSize
+	if height < 0 || offset+blockHeaderSize > int64(len(s.data)) {
+		return nil, ErrHeaderNotFound
+	}
+
+	return deserializeHeader(s.data[offset : offset+blockHeaderSize])
+}
+
+func (s *MemoryHeaderStore) Append(headers ...*wire.BlockHeader) error {
+	b, err := serializeHeaders(headers)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.data = append(s.data, b...)
+	s.lock.Unlock()
+
+	return nil
+}
+
+func (s *MemoryHeaderStore) Truncate(height int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	size := (height + 1) * blockHeaderSize
+	if size < 0 {
+		size = 0
+	}
+	if size < int64(len(s.data)) {
+		s.data = s.data[:size]
+	}
+
+	return nil
+}
+
+func (s *MemoryHeaderStore) Close() error {
+	return nil
+}
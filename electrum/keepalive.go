@@ -0,0 +1,92 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+type keepAliveConfig struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// WithKeepAlive pings the remote server every interval in the background and
+// records the round-trip latency. A ping unanswered within timeout, which
+// defaults to interval, is taken as a dead connection: the transport is
+// closed, failing pending requests and reconnecting with WithReconnect.
+func WithKeepAlive(interval, timeout time.Duration) ClientOption {
+	if timeout <= 0 {
+		timeout = interval
+	}
+
+	return func(c *Client) {
+		c.keepAlive = &keepAliveConfig{
+			interval: interval,
+			timeout:  timeout,
+		}
+	}
+}
+
+// Latency returns the round-trip time of the last keepalive ping, 0 before
+// the first one or without WithKeepAlive.
+func (s *Client) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.latency))
+}
+
+// startKeepAlive starts the keepalive loop, if enabled.
+func (s *Client) startKeepAlive() {
+	if s.keepAlive == nil || s.keepAlive.interval <= 0 {
+		return
+	}
+
+	go s.runKeepAlive()
+}
+
+func (s *Client) runKeepAlive() {
+	ticker := time.NewTicker(s.keepAlive.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+		}
+
+		// Nothing to ping while reconnecting.
+		transport := s.getTransport()
+		if transport == nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			s.keepAlive.timeout,
+		)
+		start := time.Now()
+		err := s.Ping(ctx)
+		cancel()
+
+		switch {
+		case err == nil:
+			atomic.StoreInt64(&s.latency, int64(time.Since(start)))
+
+		case errors.Is(err, ErrTimeout):
+			// The transport may have been replaced during the ping.
+			if s.getTransport() != transport {
+				continue
+			}
+
+			s.logger.Warnf(
+				"Keepalive ping unanswered after %s, closing connection",
+				s.keepAlive.timeout,
+			)
+			_ = transport.Close()
+
+		default:
+			s.logger.Debugf("Keepalive ping failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package electrum
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stallingTransport answers requests until stalled, like a half-open
+// connection, and fails its reader once closed.
+type stallingTransport struct {
+	*echoTransport
+	stalled   int32
+	closeOnce sync.Once
+}
+
+func (t *stallingTransport) SendMessage(body []byte) error {
+	if atomic.LoadInt32(&t.stalled) == 1 {
+		return nil
+	}
+	return t.echoTransport.SendMessage(body)
+}
+
+func (t *stallingTransport) Close() error {
+	t.closeOnce.Do(func() {
+		t.errors <- io.EOF
+	})
+	return nil
+}
+
+func TestKeepAlive(t *testing.T) {
+	transport := &stallingTransport{echoTransport: newEchoTransport()}
+	client := NewClient(
+		transport,
+		WithKeepAlive(10*time.Millisecond, 50*time.Millisecond),
+	)
+	defer client.Shutdown()
+
+	require.Eventually(t, func() bool {
+		return client.Latency() > 0
+	}, time.Second, 5*time.Millisecond)
+
+	atomic.StoreInt32(&transport.stalled, 1)
+
+	select {
+	case err := <-client.Error:
+		assert.ErrorIs(t, err, io.EOF)
+	case <-time.After(time.Second):
+		t.Fatal("dead connection not detected")
+	}
+}
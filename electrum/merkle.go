@@ -0,0 +1,224 @@
+package electrum
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// tipMaxAge is how long a chain tip height is trusted without asking the
+// remote server again, when not subscribed to headers.
+const tipMaxAge = 10 * time.Second
+
+var (
+	// ErrMerkleProofMismatch throws an error if a merkle proof does not lead to
+	// the merkle root of the block header.
+	ErrMerkleProofMismatch = errors.New(
+		"merkle proof does not match block merkle root",
+	)
+
+	// ErrBlockHashMismatch throws an error if a transaction claims to be in a
+	// different block than the one at its proven height.
+	ErrBlockHashMismatch = errors.New(
+		"transaction block hash does not match block header",
+	)
+
+	// ErrTxUnconfirmed throws an error if a transaction is not confirmed yet.
+	ErrTxUnconfirmed = errors.New("transaction is not confirmed")
+)
+
+// ComputeMerkleRoot hashes txHash up the merkle branch from its position in
+// the block and returns the resulting merkle root.
+// https://electrumx.readthedocs.io/en/latest/protocol-methods.html#blockchain-transaction-get-merkle
+func ComputeMerkleRoot(
+	txHash string,
+	branch []string,
+	position uint32,
+) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf [chainhash.HashSize * 2]byte
+	for _, node := range branch {
+		nodeHash, err := chainhash.NewHashFromStr(node)
+		if err != nil {
+			return nil, err
+		}
+
+		if position&1 == 1 {
+			copy(buf[:chainhash.HashSize], nodeHash[:])
+			copy(buf[chainhash.HashSize:], hash[:])
+		} else {
+			copy(buf[:chainhash.HashSize], hash[:])
+			copy(buf[chainhash.HashSize:], nodeHash[:])
+		}
+		*hash = chainhash.DoubleHashH(buf[:])
+		position >>= 1
+	}
+
+	if position != 0 {
+		return nil, fmt.Errorf(
+			"%w: position does not fit in branch",
+			ErrMerkleProofMismatch,
+		)
+	}
+
+	return hash, nil
+}
+
+// VerifyMerkleProof checks that txHash is included in the block whose merkle
+// root is merkleRoot.
+func VerifyMerkleProof(
+	txHash string,
+	proof *GetMerkleProofResult,
+	merkleRoot *chainhash.Hash,
+) error {
+	root, err := ComputeMerkleRoot(txHash, proof.Merkle, proof.Position)
+	if err != nil {
+		return err
+	}
+
+	if !root.IsEqual(merkleRoot) {
+		return ErrMerkleProofMismatch
+	}
+
+	return nil
+}
+
+// ParseBlockHeader decodes a hex encoded 80-byte block header.
+func ParseBlockHeader(headerHex string) (*wire.BlockHeader, error) {
+	b, err := hex.DecodeString(headerHex)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) != blockHeaderSize {
+		return nil, fmt.Errorf("invalid header length %d", len(b))
+	}
+
+	return deserializeHeader(b)
+}
+
+// VerifyMerkleProof checks a merkle proof returned by GetMerkleProof against
+// the merkle root of the block header at the proven height, and returns the
+// block header. The header is read from the client header chain when it
+// reaches that height, and fetched from the remote server otherwise.
+func (s *Client) VerifyMerkleProof(
+	ctx context.Context,
+	txHash string,
+	proof *GetMerkleProofResult,
+) (*wire.BlockHeader, error) {
+	header, err := s.blockHeader(ctx, int64(proof.Height))
+	if err != nil {
+		return nil, err
+	}
+
+	err = VerifyMerkleProof(txHash, proof, &header.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// GetVerifiedTransaction gets a confirmed transaction and checks its merkle
+// proof of inclusion in the block at its height. The transaction is decoded
+// from its raw hex, checked against txHash, and its block fields and
+// confirmations are filled from the proof rather than trusted from the
+// remote server. Its height is read from the cache if known, and looked up
+// in the history of its first spendable output otherwise.
+func (s *Client) GetVerifiedTransaction(
+	ctx context.Context,
+	txHash string,
+) (*GetTransactionResult, error) {
+	height := int64(-1)
+
+	var cachedTx GetTransactionResult
+	if ok := s.loadCachedTx(ctx, txHash, &cachedTx); ok &&
+		cachedTx.BlockHeight > 0 {
+		height = cachedTx.BlockHeight
+	}
+
+	tx, err := s.decodeTransaction(ctx, txHash, height)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.BlockHeight <= 0 {
+		return nil, ErrTxUnconfirmed
+	}
+
+	if cachedTx.Blockhash != "" && cachedTx.Blockhash != tx.Blockhash {
+		return nil, ErrBlockHashMismatch
+	}
+
+	return tx, nil
+}
+
+// blockHeader returns the header at height, preferring the validated local
+// header chain over the remote server.
+func (s *Client) blockHeader(
+	ctx context.Context,
+	height int64,
+) (*wire.BlockHeader, error) {
+	if s.headerChain != nil && height <= s.headerChain.Height() {
+		return s.headerChain.Header(height)
+	}
+
+	res, err := s.GetBlockHeader(ctx, uint64(height))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBlockHeader(res.Header)
+}
+
+// tipHeight returns the height of the current chain tip.
+func (s *Client) tipHeight(ctx context.Context) (int64, error) {
+	var resp SubscribeHeadersResp
+
+	err := s.request(
+		ctx,
+		"blockchain.headers.subscribe",
+		[]interface{}{},
+		&resp,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Result == nil {
+		return 0, errors.New("empty chain tip")
+	}
+
+	s.setTip(resp.Result.Height)
+	return resp.Result.Height, nil
+}
+
+// chainTip returns the last known chain tip height if it was seen less than
+// tipMaxAge ago, or asks the remote server otherwise.
+func (s *Client) chainTip(ctx context.Context) (int64, error) {
+	s.tipLock.Lock()
+	tip, tipTime := s.tip, s.tipTime
+	s.tipLock.Unlock()
+
+	if !tipTime.IsZero() && time.Since(tipTime) < tipMaxAge {
+		return tip, nil
+	}
+
+	return s.tipHeight(ctx)
+}
+
+func (s *Client) setTip(height int64) {
+	s.tipLock.Lock()
+	s.tip = height
+	s.tipTime = time.Now()
+	s.tipLock.Unlock()
+}
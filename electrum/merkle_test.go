@@ -0,0 +1,118 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyMerkleProof(t *testing.T) {
+	// Block 100000
+	root, err := chainhash.NewHashFromStr(
+		"f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766",
+	)
+	require.NoError(t, err)
+
+	txHash := "6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"
+	proof := &GetMerkleProofResult{
+		Merkle: []string{
+			"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+			"ccdafb73d8dcd0173d5d5c3c9a0770d0b3953db889dab99ef05b1907518cb815",
+		},
+		Height:   100000,
+		Position: 2,
+	}
+
+	require.NoError(t, VerifyMerkleProof(txHash, proof, root))
+
+	proof.Position = 3
+	assert.ErrorIs(t, VerifyMerkleProof(txHash, proof, root), ErrMerkleProofMismatch)
+
+	proof.Position = 4
+	assert.ErrorIs(t, VerifyMerkleProof(txHash, proof, root), ErrMerkleProofMismatch)
+}
+
+func TestGetVerifiedTransaction(t *testing.T) {
+	pkScript := append([]byte{0x00, 0x14}, make([]byte, 20)...)
+	msgTx := wire.NewMsgTx(2)
+	msgTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}},
+	})
+	msgTx.AddTxOut(wire.NewTxOut(1000, pkScript))
+	txHash := msgTx.TxHash().String()
+
+	// The only transaction of its block has its hash as merkle root.
+	header := wire.BlockHeader{MerkleRoot: msgTx.TxHash()}
+	var buf bytes.Buffer
+	require.NoError(t, header.Serialize(&buf))
+
+	history := []*GetMempoolResult{{Hash: txHash, Height: 100}}
+
+	server := newFakeServer()
+	server.on(
+		"blockchain.transaction.get",
+		func(params []interface{}) (interface{}, error) {
+			if params[1] == true {
+				// Verbose answers are not trusted.
+				return &GetTransactionResult{
+					TxID:          txHash,
+					Confirmations: 1000,
+				}, nil
+			}
+			return serializeTx(t, msgTx), nil
+		},
+	)
+	server.on(
+		"blockchain.scripthash.get_history",
+		func(params []interface{}) (interface{}, error) {
+			if params[0] != ScriptToElectrumScriptHash(pkScript) {
+				return []*GetMempoolResult{}, nil
+			}
+			return history, nil
+		},
+	)
+	server.result(
+		"blockchain.transaction.get_merkle",
+		&GetMerkleProofResult{Merkle: []string{}, Height: 100},
+	)
+	server.result("blockchain.block.header", hex.EncodeToString(buf.Bytes()))
+	server.result(
+		"blockchain.headers.subscribe",
+		&SubscribeHeadersResult{Height: 105, Hex: "00"},
+	)
+	client := newFakeClient(t, server)
+	ctx := context.Background()
+
+	tx, err := client.GetVerifiedTransaction(ctx, txHash)
+	require.NoError(t, err)
+	assert.Equal(t, txHash, tx.TxID)
+	assert.Equal(t, int64(100), tx.BlockHeight)
+	assert.Equal(t, int32(6), tx.Confirmations)
+	assert.Equal(t, header.BlockHash().String(), tx.Blockhash)
+	assert.Equal(t, btcutil.Amount(1000), tx.Vout[0].Value)
+
+	// A raw transaction not matching the hash is rejected.
+	_, err = client.GetVerifiedTransaction(ctx, chainhash.Hash{2}.String())
+	assert.Error(t, err)
+
+	// The proof must lead to the merkle root of the block.
+	header.MerkleRoot = chainhash.Hash{3}
+	buf.Reset()
+	require.NoError(t, header.Serialize(&buf))
+	server.result("blockchain.block.header", hex.EncodeToString(buf.Bytes()))
+	_, err = client.GetVerifiedTransaction(ctx, txHash)
+	assert.ErrorIs(t, err, ErrMerkleProofMismatch)
+
+	server.lock.Lock()
+	history[0].Height = 0
+	server.lock.Unlock()
+	_, err = client.GetVerifiedTransaction(ctx, txHash)
+	assert.ErrorIs(t, err, ErrTxUnconfirmed)
+}
@@ -1,15 +1,20 @@
 package electrum
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	neturl "net/url"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
 )
 
 const (
@@ -32,6 +37,9 @@ var (
 	// ErrServerShutdown throws an error if remote server has shutdown.
 	ErrServerShutdown = errors.New("server has shutdown")
 
+	// ErrNotConnected throws an error if the client is reconnecting to the remote server.
+	ErrNotConnected = errors.New("server is not connected")
+
 	// ErrTimeout throws an error if request has timed out
 	ErrTimeout = errors.New("request timeout")
 
@@ -64,7 +72,12 @@ type Logger interface {
 
 // Client stores information about the remote server.
 type Client struct {
-	transport Transport
+	transport     Transport
+	transportLock sync.RWMutex
+
+	// dial opens a new transport to the same remote server, used to reconnect.
+	dial      func(ctx context.Context) (Transport, error)
+	reconnect *reconnectConfig
 
 	handlers     map[uint64]chan *container
 	handlersLock sync.RWMutex
@@ -72,17 +85,70 @@ type Client struct {
 	pushHandlers     map[string][]chan *container
 	pushHandlersLock sync.RWMutex
 
+	// batches await the reply to a batch request, noBatch is set once the
+	// server rejected one.
+	batches     map[*pendingBatch]struct{}
+	batchesLock sync.Mutex
+	noBatch     int32
+
 	Error chan error
 	quit  chan struct{}
 
 	nextID uint64
 
 	// txCache
-	txCache *TxCache
+	txCache TxCacheStore
+
+	// rawTransactions makes transactions decoded locally from raw hex.
+	rawTransactions bool
+
+	// tip is the last known chain tip height, used to compute the
+	// confirmations of cached transactions.
+	tip     int64
+	tipTime time.Time
+	tipLock sync.Mutex
+
+	params      *chaincfg.Params
+	headerChain *HeaderChain
+	checkpoints map[uint64]string
 
 	logger Logger
 
 	timeout time.Duration
+
+	// dialerOptions configure the dialer of the transports.
+	dialerOptions []DialerOption
+
+	// proxy, proxyAuth and isolateStreams route the transports through a
+	// SOCKS5 proxy.
+	proxy          string
+	proxyAuth      *ProxyAuth
+	isolateStreams bool
+
+	// certStore and certPins verify the certificates of TLS servers.
+	certStore *CertStore
+	certPins  []string
+
+	// keepAlive configures the background ping loop, latency is the
+	// round-trip time of its last ping in nanoseconds.
+	keepAlive *keepAliveConfig
+	latency   int64
+
+	// version holds the arguments of the last successful ServerVersion call,
+	// replayed after a reconnection.
+	version     [2]string
+	versionLock sync.RWMutex
+
+	resubscribers     []func(context.Context) error
+	resubscribersLock sync.Mutex
+
+	// confirmations is the watcher shared by WaitForConfirmations calls.
+	confirmations     *ConfirmationWatcher
+	confirmationsLock sync.Mutex
+
+	// doubleSpend is the monitor shared by WatchDoubleSpend calls.
+	doubleSpend     *DoubleSpendMonitor
+	doubleSpendLock sync.Mutex
 }
 
 type ClientOption func(*Client)
@@ -99,103 +165,162 @@ func WithLogger(logger Logger) ClientOption {
 	}
 }
 
+// WithHeaderChain makes the client read block headers from a local validated
+// header chain instead of trusting the remote server, when the chain has them.
+func WithHeaderChain(chain *HeaderChain) ClientOption {
+	return func(c *Client) {
+		c.headerChain = chain
+	}
+}
+
+// NewClient initialize a new client for remote server over an already
+// connected transport. Such a client cannot reconnect on transport errors,
+// as it does not know how to dial the remote server again.
+func NewClient(transport Transport, options ...ClientOption) *Client {
+	c := newClient(options...)
+
+	c.transport = transport
+	go c.listen()
+	c.startKeepAlive()
+
+	return c
+}
+
 // NewClientTCP initialize a new client for remote server and connects to the remote server using TCP
 func NewClientTCP(
 	ctx context.Context,
 	addr string,
 	options ...ClientOption,
 ) (*Client, error) {
-	txCache, err := NewTxCache(nil)
+	c := newClient(options...)
+
+	dialer := c.dialer()
+	err := c.connect(ctx, func(ctx context.Context) (Transport, error) {
+		return dialer.DialTCP(ctx, addr)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	c := &Client{
-		handlers:     make(map[uint64]chan *container),
-		pushHandlers: make(map[string][]chan *container),
-
-		Error: make(chan error),
-		quit:  make(chan struct{}),
-
-		logger: newLogger(),
+	return c, nil
+}
 
-		txCache: txCache,
-	}
+// NewClientSSL initialize a new client for remote server and connects to the remote server using SSL
+func NewClientSSL(
+	ctx context.Context,
+	addr string,
+	config *tls.Config,
+	options ...ClientOption,
+) (*Client, error) {
+	c := newClient(options...)
 
-	for _, option := range options {
-		option(c)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
 	}
+	config = c.tlsConfig(config, host)
 
-	dialerOptions := withOptions(map[string]interface{}{
-		"timeout": c.timeout,
+	dialer := c.dialer()
+	err = c.connect(ctx, func(ctx context.Context) (Transport, error) {
+		return dialer.DialSSL(ctx, addr, config)
 	})
-
-	transport, err := NewTCPTransport(ctx, addr, dialerOptions...)
 	if err != nil {
 		return nil, err
 	}
 
-	c.transport = transport
-	go c.listen()
-
 	return c, nil
 }
 
-// NewClientSSL initialize a new client for remote server and connects to the remote server using SSL
-func NewClientSSL(
+// NewClientWS initialize a new client for remote server and connects to the remote server using WebSocket, at a ws:// or wss:// url
+func NewClientWS(
 	ctx context.Context,
-	addr string,
+	url string,
 	config *tls.Config,
 	options ...ClientOption,
 ) (*Client, error) {
-	txCache, err := NewTxCache(nil)
+	c := newClient(options...)
+
+	u, err := neturl.Parse(url)
 	if err != nil {
 		return nil, err
 	}
+	config = c.tlsConfig(config, u.Hostname())
+
+	dialer := c.dialer()
+	err = c.connect(ctx, func(ctx context.Context) (Transport, error) {
+		return dialer.DialWS(ctx, url, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
 
+// newClient creates a client configured by options, without transport.
+func newClient(options ...ClientOption) *Client {
 	c := &Client{
 		handlers:     make(map[uint64]chan *container),
 		pushHandlers: make(map[string][]chan *container),
+		batches:      make(map[*pendingBatch]struct{}),
 
 		Error: make(chan error),
 		quit:  make(chan struct{}),
 
-		logger:  newLogger(),
-		txCache: txCache,
+		logger: newLogger(),
 	}
 
 	for _, option := range options {
 		option(c)
 	}
 
-	dialerOptions := withOptions(map[string]interface{}{
-		"timeout": c.timeout,
-	})
+	if c.txCache == nil {
+		c.txCache = NewMemoryTxCache(defaultTxCacheSize)
+	}
 
-	transport, err := NewSSLTransport(ctx, addr, config, dialerOptions...)
-	if err != nil {
-		return nil, err
+	return c
+}
+
+// dialer returns the dialer of the client transports.
+func (s *Client) dialer() *Dialer {
+	d := newDialer(withOptions(map[string]interface{}{
+		"timeout": s.timeout,
+	}))
+	for _, option := range s.dialerOptions {
+		option(&d.Dialer)
 	}
 
-	c.transport = transport
-	go c.listen()
+	d.Proxy = s.proxy
+	d.ProxyAuth = s.proxyAuth
+	d.IsolateStreams = s.isolateStreams
 
-	return c, nil
+	return d
 }
 
-type apiErr struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+// connect opens the transport of the client with dial, also used to
+// reconnect, and starts listening to it.
+func (s *Client) connect(
+	ctx context.Context,
+	dial func(ctx context.Context) (Transport, error),
+) error {
+	s.dial = dial
+
+	transport, err := dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.transport = transport
+	go s.listen()
+	s.startKeepAlive()
 
-func (e *apiErr) Error() string {
-	return fmt.Sprintf("errNo: %d, errMsg: %s", e.Code, e.Message)
+	return nil
 }
 
 type response struct {
-	ID     uint64  `json:"id"`
-	Method string  `json:"method"`
-	Error  *apiErr `json:"error"`
+	ID     uint64    `json:"id"`
+	Method string    `json:"method"`
+	Error  *RPCError `json:"error"`
 }
 
 func (s *Client) listen() {
@@ -203,60 +328,137 @@ func (s *Client) listen() {
 		if s.IsShutdown() {
 			break
 		}
-		if s.transport == nil {
+		transport := s.getTransport()
+		if transport == nil {
 			break
 		}
 		select {
 		case <-s.quit:
 			return
-		case err := <-s.transport.Errors():
-			s.Error <- err
-			s.transport = nil
-			s.handlers = nil
-			s.pushHandlers = nil
-			s.Shutdown()
-		case bytes := <-s.transport.Responses():
-			result := &container{
-				content: bytes,
+		case err := <-transport.Errors():
+			s.failPending(err)
+			if s.reconnect == nil {
+				s.Error <- err
+				s.setTransport(nil)
+				// Under the locks, requests may still be in flight, e.g.
+				// keepalive pings.
+				s.handlersLock.Lock()
+				s.handlers = make(map[uint64]chan *container)
+				s.handlersLock.Unlock()
+				s.pushHandlersLock.Lock()
+				s.pushHandlers = make(map[string][]chan *container)
+				s.pushHandlersLock.Unlock()
+				s.Shutdown()
+				break
 			}
 
-			msg := &response{}
-			err := json.Unmarshal(bytes, msg)
-			if err != nil {
-				if DebugMode {
-					log.Printf("Unmarshal received message failed: %v", err)
-				}
-				result.err = fmt.Errorf(
-					"Unmarshal received message failed: %v",
-					err,
-				)
-			} else if msg.Error != nil {
-				result.err = errors.New(msg.Error.Message)
+			select {
+			case s.Error <- err:
+			default:
 			}
+			s.setTransport(nil)
+			if !s.redial() {
+				s.Shutdown()
+				break
+			}
+			go s.restore()
+		case bytes := <-transport.Responses():
+			s.handleResponse(bytes)
+		}
+	}
+}
 
-			if len(msg.Method) > 0 {
-				s.pushHandlersLock.RLock()
-				handlers := s.pushHandlers[msg.Method]
-				s.pushHandlersLock.RUnlock()
-
-				for _, handler := range handlers {
-					select {
-					case handler <- result:
-					default:
-					}
+func (s *Client) handleResponse(content []byte) {
+	// A JSON-RPC batch is answered with an array of responses.
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err == nil {
+			ids := make(map[uint64]struct{}, len(batch))
+			for _, item := range batch {
+				var msg response
+				if json.Unmarshal(item, &msg) == nil {
+					ids[msg.ID] = struct{}{}
 				}
+				s.handleResponse(item)
 			}
+			s.completeBatches(ids)
+			return
+		}
+	}
+
+	result := &container{
+		content: content,
+	}
+
+	msg := &response{}
+	err := json.Unmarshal(content, msg)
+	if err != nil {
+		if DebugMode {
+			log.Printf("Unmarshal received message failed: %v", err)
+		}
+		result.err = fmt.Errorf(
+			"Unmarshal received message failed: %v",
+			err,
+		)
+	} else if msg.Error != nil {
+		// Servers without batch support reject the array as a whole.
+		if msg.ID == 0 && len(msg.Method) == 0 {
+			s.rejectBatches(msg.Error)
+			return
+		}
 
-			s.handlersLock.RLock()
-			c, ok := s.handlers[msg.ID]
-			s.handlersLock.RUnlock()
+		msg.Error.ID = msg.ID
+		result.err = msg.Error
+	}
+
+	if len(msg.Method) > 0 {
+		s.pushHandlersLock.RLock()
+		handlers := s.pushHandlers[msg.Method]
+		s.pushHandlersLock.RUnlock()
 
-			if ok {
-				// TODO: very rare case. fix this memory leak, when nobody will read channel (in case of error)
-				c <- result
+		for _, handler := range handlers {
+			select {
+			case handler <- result:
+			default:
 			}
 		}
 	}
+
+	s.handlersLock.RLock()
+	c, ok := s.handlers[msg.ID]
+	s.handlersLock.RUnlock()
+
+	if ok {
+		// TODO: very rare case. fix this memory leak, when nobody will read channel (in case of error)
+		c <- result
+	}
+}
+
+// failPending fails every in-flight request with err, as their responses
+// will never arrive on a broken transport.
+func (s *Client) failPending(err error) {
+	s.handlersLock.RLock()
+	defer s.handlersLock.RUnlock()
+
+	for _, c := range s.handlers {
+		select {
+		case c <- &container{err: err}:
+		default:
+		}
+	}
+}
+
+func (s *Client) getTransport() Transport {
+	s.transportLock.RLock()
+	defer s.transportLock.RUnlock()
+	return s.transport
+}
+
+func (s *Client) setTransport(transport Transport) {
+	s.transportLock.Lock()
+	s.transport = transport
+	s.transportLock.Unlock()
 }
 
 func (s *Client) listenPush(method string) <-chan *container {
@@ -299,24 +501,14 @@ func (s *Client) request(
 
 	bytes = append(bytes, nl)
 
-	err = s.transport.SendMessage(bytes)
+	c := s.registerHandler(msg.ID)
+	defer s.unregisterHandler(msg.ID)
+
+	err = s.send(bytes)
 	if err != nil {
-		s.Shutdown()
 		return err
 	}
 
-	c := make(chan *container, 1)
-
-	s.handlersLock.Lock()
-	s.handlers[msg.ID] = c
-	s.handlersLock.Unlock()
-
-	defer func() {
-		s.handlersLock.Lock()
-		delete(s.handlers, msg.ID)
-		s.handlersLock.Unlock()
-	}()
-
 	var resp *container
 	select {
 	case resp = <-c:
@@ -325,7 +517,7 @@ func (s *Client) request(
 	}
 
 	if resp.err != nil {
-		return resp.err
+		return withMethod(resp.err, method)
 	}
 
 	if v != nil {
@@ -338,15 +530,50 @@ func (s *Client) request(
 	return nil
 }
 
+// registerHandler returns the chan receiving the response to request id.
+func (s *Client) registerHandler(id uint64) chan *container {
+	c := make(chan *container, 1)
+
+	s.handlersLock.Lock()
+	s.handlers[id] = c
+	s.handlersLock.Unlock()
+
+	return c
+}
+
+func (s *Client) unregisterHandler(id uint64) {
+	s.handlersLock.Lock()
+	delete(s.handlers, id)
+	s.handlersLock.Unlock()
+}
+
+// send writes a serialized message to the transport.
+func (s *Client) send(bytes []byte) error {
+	transport := s.getTransport()
+	if transport == nil {
+		return ErrNotConnected
+	}
+
+	err := transport.SendMessage(bytes)
+	if err != nil {
+		if s.reconnect != nil {
+			// Closing the transport makes the listener reconnect.
+			_ = transport.Close()
+		} else {
+			s.Shutdown()
+		}
+		return err
+	}
+
+	return nil
+}
+
 func (s *Client) Shutdown() {
 	if !s.IsShutdown() {
 		close(s.quit)
 	}
-	if s.transport != nil {
-		_ = s.transport.Close()
-	}
-	if (s.txCache) != nil {
-		s.txCache.Close()
+	if transport := s.getTransport(); transport != nil {
+		_ = transport.Close()
 	}
 	// s.transport = nil
 	// s.handlers = nil
@@ -0,0 +1,304 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoTransport is an in-process transport answering every request with
+// its method name.
+type echoTransport struct {
+	responses chan []byte
+	errors    chan error
+}
+
+func newEchoTransport() *echoTransport {
+	return &echoTransport{
+		responses: make(chan []byte, 1),
+		errors:    make(chan error, 1),
+	}
+}
+
+func (t *echoTransport) SendMessage(body []byte) error {
+	var req request
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		return err
+	}
+
+	t.responses <- []byte(fmt.Sprintf(
+		`{"jsonrpc":"2.0","id":%d,"result":%q}`,
+		req.ID,
+		req.Method,
+	))
+	return nil
+}
+
+func (t *echoTransport) Responses() <-chan []byte {
+	return t.responses
+}
+
+func (t *echoTransport) Errors() <-chan error {
+	return t.errors
+}
+
+func (t *echoTransport) Close() error {
+	return nil
+}
+
+func TestNewClient(t *testing.T) {
+	client := NewClient(newEchoTransport())
+	defer client.Shutdown()
+
+	_, ok := client.txCache.(*MemoryTxCache)
+	assert.True(t, ok)
+
+	banner, err := client.ServerBanner(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "server.banner", banner)
+}
+
+// errNoAnswer makes a fakeServer handler leave a request unanswered.
+var errNoAnswer = errors.New("no answer")
+
+// fakeHandler answers the params of a request with a result marshaled to
+// JSON, an *RPCError, or errNoAnswer. Handlers run under the server lock.
+type fakeHandler func(params []interface{}) (interface{}, error)
+
+// fakeServer scripts the answers of a remote server, over the fakeTransports
+// it dials.
+type fakeServer struct {
+	handlers map[string]fakeHandler
+	// delay postpones every answer.
+	delay time.Duration
+	// noBatch rejects JSON-RPC arrays like servers without batch support.
+	noBatch bool
+	// batchReply rewrites the answers to an array before they are sent.
+	batchReply func(answers []json.RawMessage) []json.RawMessage
+
+	transports []*fakeTransport
+	requests   []request
+
+	lock sync.Mutex
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{handlers: make(map[string]fakeHandler)}
+}
+
+// on sets the handler of method.
+func (s *fakeServer) on(method string, handler fakeHandler) {
+	s.lock.Lock()
+	s.handlers[method] = handler
+	s.lock.Unlock()
+}
+
+// result answers method with result.
+func (s *fakeServer) result(method string, result interface{}) {
+	s.on(method, func([]interface{}) (interface{}, error) {
+		return result, nil
+	})
+}
+
+func (s *fakeServer) dial(context.Context) (Transport, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t := &fakeTransport{
+		server:    s,
+		responses: make(chan []byte, 256),
+		errors:    make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+	s.transports = append(s.transports, t)
+	return t, nil
+}
+
+// transport returns the last dialed transport.
+func (s *fakeServer) transport() *fakeTransport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.transports[len(s.transports)-1]
+}
+
+// dials returns the number of dialed transports.
+func (s *fakeServer) dials() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.transports)
+}
+
+// calls returns the number of requests received for method.
+func (s *fakeServer) calls(method string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := 0
+	for _, req := range s.requests {
+		if req.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// push sends a notification on the last dialed transport.
+func (s *fakeServer) push(method string, params ...interface{}) {
+	b, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+	s.transport().deliver(b, 0)
+}
+
+// answer returns the response to req, nil to leave it unanswered.
+func (s *fakeServer) answer(req request) json.RawMessage {
+	s.requests = append(s.requests, req)
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+
+	handler, ok := s.handlers[req.Method]
+	if !ok {
+		resp["error"] = &RPCError{
+			Code:    CodeMethodNotFound,
+			Message: "unknown method " + req.Method,
+		}
+	} else {
+		result, err := handler(req.Params)
+		var rpcErr *RPCError
+		switch {
+		case errors.As(err, &rpcErr):
+			resp["error"] = rpcErr
+		case err != nil:
+			return nil
+		default:
+			resp["result"] = result
+		}
+	}
+
+	b, _ := json.Marshal(resp)
+	return b
+}
+
+// fakeTransport is an in-process transport to a fakeServer.
+type fakeTransport struct {
+	server    *fakeServer
+	responses chan []byte
+	errors    chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (t *fakeTransport) SendMessage(body []byte) error {
+	select {
+	case <-t.done:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	s := t.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	body = bytes.TrimSpace(body)
+	if body[0] != '[' {
+		var req request
+		err := json.Unmarshal(body, &req)
+		if err != nil {
+			return err
+		}
+		if answer := s.answer(req); answer != nil {
+			t.deliver(answer, s.delay)
+		}
+		return nil
+	}
+
+	if s.noBatch {
+		t.deliver([]byte(`{"jsonrpc":"2.0","id":null,"error":`+
+			`{"code":-32600,"message":"batch requests not supported"}}`),
+			s.delay,
+		)
+		return nil
+	}
+
+	var reqs []request
+	err := json.Unmarshal(body, &reqs)
+	if err != nil {
+		return err
+	}
+
+	var answers []json.RawMessage
+	for _, req := range reqs {
+		if answer := s.answer(req); answer != nil {
+			answers = append(answers, answer)
+		}
+	}
+	if s.batchReply != nil {
+		answers = s.batchReply(answers)
+	}
+
+	b, _ := json.Marshal(answers)
+	t.deliver(b, s.delay)
+	return nil
+}
+
+func (t *fakeTransport) deliver(b []byte, delay time.Duration) {
+	if delay == 0 {
+		t.responses <- b
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		select {
+		case t.responses <- b:
+		case <-t.done:
+		}
+	})
+}
+
+// fail makes the transport fail with err, like a broken connection.
+func (t *fakeTransport) fail(err error) {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.errors <- err
+	})
+}
+
+func (t *fakeTransport) Responses() <-chan []byte {
+	return t.responses
+}
+
+func (t *fakeTransport) Errors() <-chan error {
+	return t.errors
+}
+
+// Close fails the transport with io.EOF, as the reader of a closed
+// connection does.
+func (t *fakeTransport) Close() error {
+	t.fail(io.EOF)
+	return nil
+}
+
+// newFakeClient connects a client to server, able to reconnect to it.
+func newFakeClient(
+	t *testing.T,
+	server *fakeServer,
+	options ...ClientOption,
+) *Client {
+	client := newClient(options...)
+	require.NoError(t, client.connect(context.Background(), server.dial))
+	t.Cleanup(client.Shutdown)
+
+	return client
+}
@@ -0,0 +1,177 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testNet4 represents the test network (version 4).
+const testNet4 wire.BitcoinNet = 0x283f161c
+
+var (
+	// ErrUnknownNetwork throws an error if a network name is not registered.
+	ErrUnknownNetwork = errors.New("unknown network")
+
+	// ErrNetworkRegistered throws an error if a network name is already registered.
+	ErrNetworkRegistered = errors.New("network is already registered")
+
+	// ErrNetworkMismatch throws an error if the remote server does not serve
+	// the network of the client.
+	ErrNetworkMismatch = errors.New("server genesis does not match network")
+)
+
+var testNet4GenesisHash = chainhash.Hash{
+	0x43, 0xf0, 0x8b, 0xda, 0xb0, 0x50, 0xe3, 0x5b,
+	0x56, 0x7c, 0x86, 0x4b, 0x91, 0xf4, 0x7f, 0x50,
+	0xae, 0x72, 0x5a, 0xe2, 0xde, 0x53, 0xbc, 0xfb,
+	0xba, 0xf2, 0x84, 0xda, 0x00, 0x00, 0x00, 0x00,
+}
+
+var testNet4GenesisMerkleRoot = chainhash.Hash{
+	0x4e, 0x7b, 0x2b, 0x91, 0x28, 0xfe, 0x02, 0x91,
+	0xdb, 0x06, 0x93, 0xaf, 0x2a, 0xe4, 0x18, 0xb7,
+	0x67, 0xe6, 0x57, 0xcd, 0x40, 0x7e, 0x80, 0xcb,
+	0x14, 0x34, 0x22, 0x1e, 0xae, 0xa7, 0xa0, 0x7a,
+}
+
+// TestNet4Params defines the network parameters for the test Bitcoin network
+// (version 4), defined in BIP94. Its genesis block only holds the header.
+var TestNet4Params = func() chaincfg.Params {
+	params := chaincfg.TestNet3Params
+	params.Name = "testnet4"
+	params.Net = testNet4
+	params.DefaultPort = "48333"
+	params.DNSSeeds = nil
+	params.GenesisBlock = &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: testNet4GenesisMerkleRoot,
+			Timestamp:  time.Unix(1714777860, 0),
+			Bits:       0x1d00ffff,
+			Nonce:      393743547,
+		},
+	}
+	params.GenesisHash = &testNet4GenesisHash
+	params.Checkpoints = nil
+	return params
+}()
+
+var (
+	networks = map[string]*chaincfg.Params{
+		chaincfg.MainNetParams.Name:       &chaincfg.MainNetParams,
+		chaincfg.TestNet3Params.Name:      &chaincfg.TestNet3Params,
+		TestNet4Params.Name:               &TestNet4Params,
+		chaincfg.SigNetParams.Name:        &chaincfg.SigNetParams,
+		chaincfg.RegressionNetParams.Name: &chaincfg.RegressionNetParams,
+	}
+	// noRetargeting holds the genesis hashes of the networks whose
+	// difficulty never changes, which chaincfg params cannot tell.
+	noRetargeting = map[chainhash.Hash]struct{}{
+		*chaincfg.RegressionNetParams.GenesisHash: {},
+	}
+	networksLock sync.RWMutex
+)
+
+// RegisterNetwork makes custom network params available by name through
+// NetworkByName, e.g. for a custom signet.
+func RegisterNetwork(params *chaincfg.Params) error {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+
+	name := strings.ToLower(params.Name)
+	if _, ok := networks[name]; ok {
+		return fmt.Errorf("%w: %s", ErrNetworkRegistered, params.Name)
+	}
+
+	networks[name] = params
+	return nil
+}
+
+// RegisterNoRetargeting makes header chains of the network with the genesis
+// of params never retarget their difficulty, like regtest does.
+func RegisterNoRetargeting(params *chaincfg.Params) {
+	networksLock.Lock()
+	noRetargeting[*params.GenesisHash] = struct{}{}
+	networksLock.Unlock()
+}
+
+// powNoRetargeting reports whether the difficulty of the network is fixed.
+func powNoRetargeting(params *chaincfg.Params) bool {
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	_, ok := noRetargeting[*params.GenesisHash]
+	return ok
+}
+
+// NetworkByName returns the params of a built-in or registered network:
+// mainnet, testnet3, testnet4, signet or regtest.
+func NetworkByName(name string) (*chaincfg.Params, error) {
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	params, ok := networks[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNetwork, name)
+	}
+
+	return params, nil
+}
+
+// WithNetwork sets the network of the client, used to decode addresses and
+// validate headers. Defaults to mainnet.
+func WithNetwork(params *chaincfg.Params) ClientOption {
+	return func(c *Client) {
+		c.params = params
+	}
+}
+
+// Network returns the network params of the client.
+func (s *Client) Network() *chaincfg.Params {
+	if s.params == nil {
+		return &chaincfg.MainNetParams
+	}
+	return s.params
+}
+
+// network returns the per-call network override if any, the client network
+// otherwise.
+func (s *Client) network(params []*chaincfg.Params) *chaincfg.Params {
+	if len(params) > 0 && params[0] != nil {
+		return params[0]
+	}
+	return s.Network()
+}
+
+// NewHeaderChain creates a header chain for the client network.
+func (s *Client) NewHeaderChain(store HeaderStore) *HeaderChain {
+	return NewHeaderChain(s.Network(), store)
+}
+
+// CheckNetwork checks that the remote server serves the client network by
+// comparing genesis block hashes.
+func (s *Client) CheckNetwork(ctx context.Context) error {
+	features, err := s.ServerFeatures(ctx)
+	if err != nil {
+		return err
+	}
+
+	genesis := s.Network().GenesisHash.String()
+	if features == nil || !strings.EqualFold(features.GenesisHash, genesis) {
+		return fmt.Errorf(
+			"%w: expected %s",
+			ErrNetworkMismatch,
+			s.Network().Name,
+		)
+	}
+
+	return nil
+}
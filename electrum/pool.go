@@ -0,0 +1,593 @@
+package electrum
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultMaxErrorRate        = 0.5
+
+	// errorRateWeight is the weight of the latest call in the error rate moving average.
+	errorRateWeight = 0.2
+)
+
+var (
+	// ErrNoServerAvailable throws an error if no server of the pool could serve the request.
+	ErrNoServerAvailable = errors.New("no server available in pool")
+)
+
+// PoolServer describes a remote server member of a Pool.
+type PoolServer struct {
+	Addr string
+	// TLSConfig connects to the server using SSL if set, using TCP otherwise.
+	TLSConfig *tls.Config
+}
+
+// PoolServerStatus reports the health of a server member of a Pool.
+type PoolServerStatus struct {
+	Addr      string
+	Healthy   bool
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+type poolServer struct {
+	PoolServer
+
+	client    *Client
+	healthy   bool
+	latency   time.Duration
+	errorRate float64
+
+	lock sync.Mutex
+}
+
+// Pool routes requests across several remote servers, failing over to the next
+// healthy server when one times out, shuts down or has a broken transport.
+// Servers are evicted when their ping fails, their latency is too high or their
+// error rate is too high, and readmitted once a health check ping succeeds.
+// Evicted servers are only used when no healthy server is left.
+type Pool struct {
+	servers []*poolServer
+
+	clientOptions       []ClientOption
+	healthCheckInterval time.Duration
+	maxLatency          time.Duration
+	maxErrorRate        float64
+	requestTimeout      time.Duration
+
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	logger Logger
+}
+
+type PoolOption func(*Pool)
+
+// WithPoolClientOptions sets the options used to create every Client of the pool.
+func WithPoolClientOptions(options ...ClientOption) PoolOption {
+	return func(p *Pool) {
+		p.clientOptions = append(p.clientOptions, options...)
+	}
+}
+
+// WithHealthCheckInterval sets how often every server of the pool is pinged.
+func WithHealthCheckInterval(interval time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.healthCheckInterval = interval
+	}
+}
+
+// WithMaxLatency evicts servers whose ping latency exceeds maxLatency.
+func WithMaxLatency(maxLatency time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.maxLatency = maxLatency
+	}
+}
+
+// WithMaxErrorRate evicts servers whose recent error rate, between 0 and 1,
+// exceeds maxErrorRate.
+func WithMaxErrorRate(maxErrorRate float64) PoolOption {
+	return func(p *Pool) {
+		p.maxErrorRate = maxErrorRate
+	}
+}
+
+// WithRequestTimeout bounds every attempt on a single server, so a slow server
+// leaves time to fail over to the next one.
+func WithRequestTimeout(timeout time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.requestTimeout = timeout
+	}
+}
+
+func WithPoolLogger(logger Logger) PoolOption {
+	return func(p *Pool) {
+		p.logger = logger
+	}
+}
+
+// NewPool connects to the given servers and starts monitoring their health.
+// It fails only if none of the servers could be reached.
+func NewPool(
+	ctx context.Context,
+	servers []PoolServer,
+	options ...PoolOption,
+) (*Pool, error) {
+	p := &Pool{
+		healthCheckInterval: defaultHealthCheckInterval,
+		maxErrorRate:        defaultMaxErrorRate,
+
+		quit: make(chan struct{}),
+
+		logger: newLogger(),
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		ps := &poolServer{PoolServer: server}
+		p.servers = append(p.servers, ps)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.connect(ctx, ps)
+		}()
+	}
+	wg.Wait()
+
+	connected := false
+	for _, ps := range p.servers {
+		if ps.client != nil {
+			connected = true
+		}
+	}
+	if !connected {
+		return nil, ErrNoServerAvailable
+	}
+
+	go p.healthCheck()
+
+	return p, nil
+}
+
+// connect dials the server and negotiates the protocol version.
+func (p *Pool) connect(ctx context.Context, ps *poolServer) {
+	var client *Client
+	var err error
+
+	if ps.TLSConfig != nil {
+		client, err = NewClientSSL(
+			ctx,
+			ps.Addr,
+			ps.TLSConfig,
+			p.clientOptions...,
+		)
+	} else {
+		client, err = NewClientTCP(ctx, ps.Addr, p.clientOptions...)
+	}
+	if err != nil {
+		p.logger.Warnf("Connect to %s failed: %v", ps.Addr, err)
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case <-client.quit:
+				return
+			case err := <-client.Error:
+				p.logger.Warnf("Server %s transport error: %v", ps.Addr, err)
+				ps.lock.Lock()
+				ps.healthy = false
+				ps.lock.Unlock()
+			}
+		}
+	}()
+
+	start := time.Now()
+	_, _, err = client.ServerVersion(ctx, "", "")
+	if err != nil {
+		p.logger.Warnf("Negotiate version with %s failed: %v", ps.Addr, err)
+		client.Shutdown()
+		return
+	}
+
+	ps.lock.Lock()
+	ps.client = client
+	ps.healthy = true
+	ps.latency = time.Since(start)
+	ps.errorRate = 0
+	ps.lock.Unlock()
+}
+
+func (p *Pool) healthCheck() {
+	ticker := time.NewTicker(p.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-ticker.C:
+		}
+
+		var wg sync.WaitGroup
+		for _, ps := range p.servers {
+			ps := ps
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.checkServer(ps)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func (p *Pool) checkServer(ps *poolServer) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		p.healthCheckInterval,
+	)
+	defer cancel()
+
+	ps.lock.Lock()
+	client := ps.client
+	ps.lock.Unlock()
+
+	if client == nil || client.IsShutdown() {
+		p.connect(ctx, ps)
+		return
+	}
+
+	start := time.Now()
+	err := client.Ping(ctx)
+	latency := time.Since(start)
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if err != nil {
+		if ps.healthy {
+			p.logger.Warnf("Evicting %s: ping failed: %v", ps.Addr, err)
+		}
+		ps.healthy = false
+		return
+	}
+
+	if ps.latency == 0 {
+		ps.latency = latency
+	} else {
+		ps.latency = (ps.latency + latency) / 2
+	}
+	ps.errorRate *= 1 - errorRateWeight
+
+	switch {
+	case p.maxLatency > 0 && ps.latency > p.maxLatency:
+		if ps.healthy {
+			p.logger.Warnf("Evicting %s: latency %s", ps.Addr, ps.latency)
+		}
+		ps.healthy = false
+	case ps.errorRate > p.maxErrorRate:
+		if ps.healthy {
+			p.logger.Warnf(
+				"Evicting %s: error rate %.2f",
+				ps.Addr,
+				ps.errorRate,
+			)
+		}
+		ps.healthy = false
+	case !ps.healthy:
+		p.logger.Infof("Readmitting %s", ps.Addr)
+		ps.healthy = true
+		ps.errorRate = 0
+	}
+}
+
+// record updates the server error rate with the outcome of a call.
+func (ps *poolServer) record(failed bool, maxErrorRate float64) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	outcome := 0.0
+	if failed {
+		outcome = 1
+	}
+	ps.errorRate = ps.errorRate*(1-errorRateWeight) +
+		outcome*errorRateWeight
+
+	if ps.errorRate > maxErrorRate {
+		ps.healthy = false
+	}
+}
+
+// candidates returns the connected healthy servers ordered by latency.
+// Evicted servers are only returned, also by latency, when no healthy
+// server is left.
+func (p *Pool) candidates() []*poolServer {
+	type candidate struct {
+		server  *poolServer
+		latency time.Duration
+	}
+
+	var healthy, evicted []candidate
+	for _, ps := range p.servers {
+		ps.lock.Lock()
+		if ps.client != nil && !ps.client.IsShutdown() {
+			if ps.healthy {
+				healthy = append(healthy, candidate{ps, ps.latency})
+			} else {
+				evicted = append(evicted, candidate{ps, ps.latency})
+			}
+		}
+		ps.lock.Unlock()
+	}
+
+	list := healthy
+	if len(list) == 0 {
+		list = evicted
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].latency < list[j].latency
+	})
+
+	servers := make([]*poolServer, len(list))
+	for i, c := range list {
+		servers[i] = c.server
+	}
+	return servers
+}
+
+// Do calls fn with the client of the best available server, failing over to
+// the next one when fn returns a timeout, shutdown or transport error.
+func (p *Pool) Do(
+	ctx context.Context,
+	fn func(ctx context.Context, client *Client) error,
+) error {
+	err := ErrNoServerAvailable
+	for _, ps := range p.candidates() {
+		if ctx.Err() != nil {
+			return ErrTimeout
+		}
+
+		ps.lock.Lock()
+		client := ps.client
+		ps.lock.Unlock()
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.requestTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, p.requestTimeout)
+		}
+		err = fn(attemptCtx, client)
+		cancel()
+
+		failover := isFailoverError(err)
+		ps.record(failover, p.maxErrorRate)
+		if !failover {
+			return err
+		}
+
+		p.logger.Warnf(
+			"Request to %s failed, failing over: %v",
+			ps.Addr,
+			err,
+		)
+	}
+
+	return err
+}
+
+// isFailoverError reports whether err means the server could not answer or
+// is throttling the session, rather than the server answering with an error.
+func isFailoverError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrServerShutdown) ||
+		errors.Is(err, ErrNotConnected) ||
+		errors.Is(err, ErrExcessiveResourceUsage) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func poolCall[T any](
+	ctx context.Context,
+	p *Pool,
+	call func(ctx context.Context, client *Client) (T, error),
+) (T, error) {
+	var result T
+	err := p.Do(ctx, func(ctx context.Context, client *Client) error {
+		var err error
+		result, err = call(ctx, client)
+		return err
+	})
+	return result, err
+}
+
+// Status returns the health of every server of the pool.
+func (p *Pool) Status() []PoolServerStatus {
+	status := make([]PoolServerStatus, 0, len(p.servers))
+	for _, ps := range p.servers {
+		ps.lock.Lock()
+		status = append(status, PoolServerStatus{
+			Addr:      ps.Addr,
+			Healthy:   ps.healthy && ps.client != nil && !ps.client.IsShutdown(),
+			Latency:   ps.latency,
+			ErrorRate: ps.errorRate,
+		})
+		ps.lock.Unlock()
+	}
+	return status
+}
+
+// Shutdown stops the health checks and closes every client of the pool.
+func (p *Pool) Shutdown() {
+	p.quitOnce.Do(func() {
+		close(p.quit)
+	})
+
+	for _, ps := range p.servers {
+		ps.lock.Lock()
+		if ps.client != nil {
+			ps.client.Shutdown()
+		}
+		ps.lock.Unlock()
+	}
+}
+
+// GetBalance returns the balance of a scripthash from a healthy server.
+func (p *Pool) GetBalance(
+	ctx context.Context,
+	scripthash string,
+) (GetBalanceResult, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) (GetBalanceResult, error) {
+			return c.GetBalance(ctx, scripthash)
+		},
+	)
+}
+
+// GetHistory returns the history of a scripthash from a healthy server.
+func (p *Pool) GetHistory(
+	ctx context.Context,
+	scripthash string,
+) ([]*GetMempoolResult, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) ([]*GetMempoolResult, error) {
+			return c.GetHistory(ctx, scripthash)
+		},
+	)
+}
+
+// GetMempool returns the unconfirmed transactions of a scripthash from a healthy server.
+func (p *Pool) GetMempool(
+	ctx context.Context,
+	scripthash string,
+) ([]*GetMempoolResult, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) ([]*GetMempoolResult, error) {
+			return c.GetMempool(ctx, scripthash)
+		},
+	)
+}
+
+// ListUnspent returns the UTXOs of a scripthash from a healthy server.
+func (p *Pool) ListUnspent(
+	ctx context.Context,
+	scripthash string,
+) ([]*ListUnspentResult, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) ([]*ListUnspentResult, error) {
+			return c.ListUnspent(ctx, scripthash)
+		},
+	)
+}
+
+// GetTransaction gets the detailed information for a transaction from a healthy server.
+func (p *Pool) GetTransaction(
+	ctx context.Context,
+	txHash string,
+) (*GetTransactionResult, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) (*GetTransactionResult, error) {
+			return c.GetTransaction(ctx, txHash)
+		},
+	)
+}
+
+// GetRawTransaction gets a raw encoded transaction from a healthy server.
+func (p *Pool) GetRawTransaction(
+	ctx context.Context,
+	txHash string,
+) (string, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) (string, error) {
+			return c.GetRawTransaction(ctx, txHash)
+		},
+	)
+}
+
+// BroadcastTransaction broadcasts a raw transaction through a healthy server.
+// A server failing over may have broadcast the transaction anyway, so a retry
+// finding it already in the mempool or the chain succeeds.
+func (p *Pool) BroadcastTransaction(
+	ctx context.Context,
+	rawTx string,
+) (string, error) {
+	retry := false
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) (string, error) {
+			txID, err := c.BroadcastTransaction(ctx, rawTx)
+			if retry && (errors.Is(err, ErrTxAlreadyInMempool) ||
+				errors.Is(err, ErrTxAlreadyInChain)) {
+				tx, decodeErr := DecodeTransaction(rawTx, c.Network())
+				if decodeErr != nil {
+					return "", err
+				}
+				return tx.TxID, nil
+			}
+			retry = true
+			return txID, err
+		},
+	)
+}
+
+// GetBlockHeader returns the block header at a specific height from a healthy server.
+func (p *Pool) GetBlockHeader(
+	ctx context.Context,
+	height uint64,
+	checkpointHeight ...uint64,
+) (*GetBlockHeaderResult, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) (*GetBlockHeaderResult, error) {
+			return c.GetBlockHeader(ctx, height, checkpointHeight...)
+		},
+	)
+}
+
+// GetFee returns the estimated fee per kilobyte from a healthy server.
+func (p *Pool) GetFee(ctx context.Context, target uint32) (float32, error) {
+	return poolCall(
+		ctx,
+		p,
+		func(ctx context.Context, c *Client) (float32, error) {
+			return c.GetFee(ctx, target)
+		},
+	)
+}
@@ -0,0 +1,276 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPool builds a pool over clients of the given servers, ordered by
+// latency, without starting the health checks.
+func newTestPool(
+	t *testing.T,
+	servers []*fakeServer,
+	options ...PoolOption,
+) *Pool {
+	p := &Pool{
+		healthCheckInterval: defaultHealthCheckInterval,
+		maxErrorRate:        defaultMaxErrorRate,
+
+		quit: make(chan struct{}),
+
+		logger: newLogger(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+
+	for i, server := range servers {
+		transport, err := server.dial(context.Background())
+		require.NoError(t, err)
+
+		p.servers = append(p.servers, &poolServer{
+			PoolServer: PoolServer{Addr: fmt.Sprintf("server%d", i)},
+			client:     NewClient(transport),
+			healthy:    true,
+			latency:    time.Duration(i+1) * time.Millisecond,
+		})
+	}
+	t.Cleanup(p.Shutdown)
+
+	return p
+}
+
+func balanceServer(confirmed int64) *fakeServer {
+	server := newFakeServer()
+	server.result("server.ping", nil)
+	server.result(
+		"blockchain.scripthash.get_balance",
+		map[string]int64{"confirmed": confirmed, "unconfirmed": 0},
+	)
+	return server
+}
+
+func silentServer() *fakeServer {
+	server := newFakeServer()
+	server.result("server.ping", nil)
+	noAnswer := func([]interface{}) (interface{}, error) {
+		return nil, errNoAnswer
+	}
+	server.on("blockchain.scripthash.get_balance", noAnswer)
+	server.on("blockchain.transaction.broadcast", noAnswer)
+	return server
+}
+
+func TestPoolFailover(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("timeout", func(t *testing.T) {
+		p := newTestPool(
+			t,
+			[]*fakeServer{silentServer(), balanceServer(2)},
+			WithRequestTimeout(20*time.Millisecond),
+		)
+
+		balance, err := p.GetBalance(ctx, "scripthash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, balance.Confirmed)
+		assert.Greater(t, p.Status()[0].ErrorRate, 0.0)
+		assert.Zero(t, p.Status()[1].ErrorRate)
+	})
+
+	t.Run("not connected", func(t *testing.T) {
+		p := newTestPool(
+			t,
+			[]*fakeServer{balanceServer(1), balanceServer(2)},
+		)
+		p.servers[0].client.setTransport(nil)
+
+		balance, err := p.GetBalance(ctx, "scripthash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, balance.Confirmed)
+	})
+
+	t.Run("all servers failing", func(t *testing.T) {
+		p := newTestPool(
+			t,
+			[]*fakeServer{silentServer(), silentServer()},
+			WithRequestTimeout(10*time.Millisecond),
+		)
+
+		_, err := p.GetBalance(ctx, "scripthash")
+		assert.ErrorIs(t, err, ErrTimeout)
+	})
+}
+
+func TestPoolNoFailoverOnRPCError(t *testing.T) {
+	rejecting := newFakeServer()
+	rejecting.on(
+		"blockchain.scripthash.get_balance",
+		func([]interface{}) (interface{}, error) {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "bad"}
+		},
+	)
+	backup := balanceServer(2)
+	p := newTestPool(t, []*fakeServer{rejecting, backup})
+
+	_, err := p.GetBalance(context.Background(), "scripthash")
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeInvalidParams, rpcErr.Code)
+	assert.Zero(t, backup.calls("blockchain.scripthash.get_balance"))
+	assert.Zero(t, p.Status()[0].ErrorRate)
+}
+
+func TestPoolBroadcastRetry(t *testing.T) {
+	msgTx := wire.NewMsgTx(2)
+	msgTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}},
+	})
+	msgTx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	rawTx := serializeTx(t, msgTx)
+
+	rejectKnown := func(message string) *fakeServer {
+		server := newFakeServer()
+		server.on(
+			"blockchain.transaction.broadcast",
+			func([]interface{}) (interface{}, error) {
+				return nil, &RPCError{Code: CodeDaemonError, Message: message}
+			},
+		)
+		return server
+	}
+
+	// The first server may have relayed the transaction before timing out.
+	for _, message := range []string{
+		"txn-already-in-mempool",
+		"Transaction outputs already in utxo set",
+	} {
+		p := newTestPool(
+			t,
+			[]*fakeServer{silentServer(), rejectKnown(message)},
+			WithRequestTimeout(20*time.Millisecond),
+		)
+
+		txID, err := p.BroadcastTransaction(context.Background(), rawTx)
+		require.NoError(t, err, message)
+		assert.Equal(t, msgTx.TxHash().String(), txID)
+	}
+
+	// Without a previous attempt, the transaction was broadcast by another
+	// wallet or twice by the caller.
+	p := newTestPool(t, []*fakeServer{rejectKnown("txn-already-in-mempool")})
+	_, err := p.BroadcastTransaction(context.Background(), rawTx)
+	assert.ErrorIs(t, err, ErrTxAlreadyInMempool)
+}
+
+func TestPoolEviction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("latency", func(t *testing.T) {
+		slow := balanceServer(1)
+		slow.delay = 30 * time.Millisecond
+		p := newTestPool(
+			t,
+			[]*fakeServer{slow, balanceServer(2)},
+			WithMaxLatency(10*time.Millisecond),
+		)
+
+		p.checkServer(p.servers[0])
+		assert.False(t, p.Status()[0].Healthy)
+
+		// Evicted servers are not tried while a healthy one is left.
+		balance, err := p.GetBalance(ctx, "scripthash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, balance.Confirmed)
+		assert.Zero(t, slow.calls("blockchain.scripthash.get_balance"))
+	})
+
+	t.Run("error rate and readmission", func(t *testing.T) {
+		failing := silentServer()
+		p := newTestPool(
+			t,
+			[]*fakeServer{failing, balanceServer(2)},
+			WithRequestTimeout(10*time.Millisecond),
+			WithMaxErrorRate(0.5),
+		)
+
+		// The error rate exceeds 0.5 after the 4th consecutive failure.
+		for i := 0; i < 5; i++ {
+			_, err := p.GetBalance(ctx, "scripthash")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 4, failing.calls("blockchain.scripthash.get_balance"))
+		assert.False(t, p.Status()[0].Healthy)
+
+		// A successful health check lowers the error rate below the
+		// maximum and readmits the server.
+		p.checkServer(p.servers[0])
+		status := p.Status()[0]
+		assert.True(t, status.Healthy)
+		assert.Zero(t, status.ErrorRate)
+	})
+
+	t.Run("out of rotation", func(t *testing.T) {
+		evicted := balanceServer(1)
+		p := newTestPool(
+			t,
+			[]*fakeServer{evicted, silentServer()},
+			WithRequestTimeout(10*time.Millisecond),
+		)
+		p.servers[0].healthy = false
+
+		// Failing over skips the evicted server.
+		_, err := p.GetBalance(ctx, "scripthash")
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.Zero(t, evicted.calls("blockchain.scripthash.get_balance"))
+
+		// Once every server is evicted, they are used anyway.
+		p.servers[1].healthy = false
+		balance, err := p.GetBalance(ctx, "scripthash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, balance.Confirmed)
+	})
+
+	t.Run("ping failure", func(t *testing.T) {
+		p := newTestPool(
+			t,
+			[]*fakeServer{newFakeServer(), balanceServer(2)},
+		)
+
+		p.checkServer(p.servers[0])
+		assert.False(t, p.Status()[0].Healthy)
+	})
+}
+
+func TestIsFailoverError(t *testing.T) {
+	tests := []struct {
+		err      error
+		failover bool
+	}{
+		{nil, false},
+		{ErrTimeout, true},
+		{ErrServerShutdown, true},
+		{ErrNotConnected, true},
+		{&RPCError{Code: CodeExcessiveResourceUsage, Message: "busy"}, true},
+		{io.EOF, true},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "read", Err: errors.New("reset")}, true},
+		{&RPCError{Code: CodeDaemonError, Message: "error"}, false},
+		{ErrTxAlreadyInMempool, false},
+		{errors.New("decode failed"), false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.failover, isFailoverError(test.err), test.err)
+	}
+}
@@ -0,0 +1,290 @@
+package electrum
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5NoAuth       = 0x00
+	socks5UserPassAuth = 0x02
+
+	socks5UserPassVersion = 0x01
+
+	socks5Connect = 0x01
+
+	socks5IPv4   = 0x01
+	socks5Domain = 0x03
+	socks5IPv6   = 0x04
+)
+
+var (
+	// ErrProxyAuth throws an error if the SOCKS5 proxy rejects the
+	// authentication method or the credentials.
+	ErrProxyAuth = errors.New("socks5 proxy authentication failed")
+
+	// ErrProxyProtocol throws an error if the SOCKS5 proxy answers with an
+	// invalid message.
+	ErrProxyProtocol = errors.New("invalid socks5 proxy response")
+)
+
+// socks5Replies describes the failure codes of a SOCKS5 connect reply.
+var socks5Replies = map[byte]string{
+	0x01: "general failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// ProxyAuth holds the username and password sent to a SOCKS5 proxy. Tor
+// isolates streams opened with different credentials on separate circuits.
+type ProxyAuth struct {
+	Username string
+	Password string
+}
+
+// Dialer opens the connections of the TCP, SSL and WebSocket transports,
+// directly or through a SOCKS5 proxy.
+type Dialer struct {
+	net.Dialer
+
+	// Proxy is the address of a SOCKS5 proxy, e.g. 127.0.0.1:9050 for a
+	// local Tor daemon. Host names are resolved by the proxy, which is
+	// required for .onion servers.
+	Proxy     string
+	ProxyAuth *ProxyAuth
+
+	// IsolateStreams sends random credentials to the proxy for every
+	// connection, so that Tor routes each one on its own circuit.
+	IsolateStreams bool
+}
+
+// WithDialerOptions configures the dialer of the client transports.
+func WithDialerOptions(options ...DialerOption) ClientOption {
+	return func(c *Client) {
+		c.dialerOptions = append(c.dialerOptions, options...)
+	}
+}
+
+// WithSOCKS5Proxy dials the remote server through the SOCKS5 proxy at addr,
+// with optional credentials.
+func WithSOCKS5Proxy(addr string, auth *ProxyAuth) ClientOption {
+	return func(c *Client) {
+		c.proxy = addr
+		c.proxyAuth = auth
+	}
+}
+
+// WithStreamIsolation sends random proxy credentials for every connection,
+// overriding those of WithSOCKS5Proxy.
+func WithStreamIsolation() ClientOption {
+	return func(c *Client) {
+		c.isolateStreams = true
+	}
+}
+
+// DialContext connects to addr, through the proxy if one is set.
+func (d *Dialer) DialContext(
+	ctx context.Context,
+	network, addr string,
+) (net.Conn, error) {
+	if d.Proxy == "" {
+		return d.Dialer.DialContext(ctx, network, addr)
+	}
+
+	auth := d.ProxyAuth
+	if d.IsolateStreams {
+		var err error
+		auth, err = randomProxyAuth()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := d.Dialer.DialContext(ctx, "tcp", d.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake is bounded by the dial timeout, as for a direct dial.
+	deadline, ok := ctx.Deadline()
+	if d.Timeout > 0 {
+		timeout := time.Now().Add(d.Timeout)
+		if !ok || timeout.Before(deadline) {
+			deadline, ok = timeout, true
+		}
+	}
+	if ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	err = socks5Handshake(conn, addr, auth)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("socks5 proxy %s: %w", d.Proxy, err)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+func randomProxyAuth() (*ProxyAuth, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProxyAuth{
+		Username: hex.EncodeToString(b[:8]),
+		Password: hex.EncodeToString(b[8:]),
+	}, nil
+}
+
+// socks5Handshake asks the proxy behind conn to connect to addr (RFC 1928),
+// authenticating with auth if set (RFC 1929).
+func socks5Handshake(conn net.Conn, addr string, auth *ProxyAuth) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+
+	method := byte(socks5NoAuth)
+	if auth != nil {
+		method = socks5UserPassAuth
+	}
+
+	_, err = conn.Write([]byte{socks5Version, 1, method})
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+
+	if reply[0] != socks5Version {
+		return ErrProxyProtocol
+	}
+	if reply[1] != method {
+		return ErrProxyAuth
+	}
+
+	if auth != nil {
+		err = socks5Authenticate(conn, auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5IPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5IPv6)
+		req = append(req, ip...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return err
+	}
+
+	if header[0] != socks5Version {
+		return ErrProxyProtocol
+	}
+	if header[1] != 0 {
+		reason, ok := socks5Replies[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("error %d", header[1])
+		}
+		return fmt.Errorf("connect to %s failed: %s", addr, reason)
+	}
+
+	// Skip the bound address and port.
+	var size int
+	switch header[3] {
+	case socks5IPv4:
+		size = net.IPv4len + 2
+	case socks5IPv6:
+		size = net.IPv6len + 2
+	case socks5Domain:
+		length := make([]byte, 1)
+		_, err = io.ReadFull(conn, length)
+		if err != nil {
+			return err
+		}
+		size = int(length[0]) + 2
+	default:
+		return ErrProxyProtocol
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, size))
+	return err
+}
+
+func socks5Authenticate(conn net.Conn, auth *ProxyAuth) error {
+	if len(auth.Username) > 255 || len(auth.Password) > 255 {
+		return errors.New("proxy credentials too long")
+	}
+
+	req := []byte{socks5UserPassVersion, byte(len(auth.Username))}
+	req = append(req, auth.Username...)
+	req = append(req, byte(len(auth.Password)))
+	req = append(req, auth.Password...)
+
+	_, err := conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+
+	if reply[0] != socks5UserPassVersion {
+		return ErrProxyProtocol
+	}
+	if reply[1] != 0 {
+		return ErrProxyAuth
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package electrum
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socks5Request is what a fake proxy received from a client.
+type socks5Request struct {
+	username, password string
+	host               string
+	port               int
+}
+
+// serveSOCKS5 reads a user/password SOCKS5 handshake from conn, then echoes
+// the lines it receives. It runs off the test goroutine, so failures are
+// only asserted.
+func serveSOCKS5(t *testing.T, conn net.Conn, requests chan<- socks5Request) {
+	defer conn.Close()
+
+	// After a failed read, the next ones return zeros and are not tried.
+	var err error
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		if err == nil {
+			_, err = io.ReadFull(conn, b)
+		}
+		return b
+	}
+
+	greeting := read(2)
+	methods := read(int(greeting[1]))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []byte{socks5UserPassAuth}, methods)
+	conn.Write([]byte{socks5Version, socks5UserPassAuth})
+
+	var req socks5Request
+	read(1)
+	req.username = string(read(int(read(1)[0])))
+	req.password = string(read(int(read(1)[0])))
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn.Write([]byte{socks5UserPassVersion, 0})
+
+	header := read(4)
+	req.host = string(read(int(read(1)[0])))
+	port := read(2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, byte(socks5Domain), header[3])
+	req.port = int(port[0])<<8 | int(port[1])
+	conn.Write([]byte{socks5Version, 0, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+
+	requests <- req
+	io.Copy(conn, conn)
+}
+
+func TestSOCKS5Transport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	requests := make(chan socks5Request, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(t, conn, requests)
+		}
+	}()
+
+	addr := "electrum3vflbcj4vg2ly3ssssf7vmlwy7zgpkpogwe2bdrgmxpfqbqd.onion:50001"
+
+	dialer := &Dialer{
+		Proxy:     ln.Addr().String(),
+		ProxyAuth: &ProxyAuth{"user", "pass"},
+	}
+	transport, err := dialer.DialTCP(context.Background(), addr)
+	require.NoError(t, err)
+	defer transport.Close()
+
+	req := <-requests
+	assert.Equal(t, socks5Request{
+		username: "user",
+		password: "pass",
+		host:     "electrum3vflbcj4vg2ly3ssssf7vmlwy7zgpkpogwe2bdrgmxpfqbqd.onion",
+		port:     50001,
+	}, req)
+
+	require.NoError(t, transport.SendMessage([]byte("ping\n")))
+	assert.Equal(t, []byte("ping\n"), <-transport.Responses())
+
+	// Isolated streams use random credentials.
+	client, err := NewClientTCP(
+		context.Background(),
+		addr,
+		WithSOCKS5Proxy(ln.Addr().String(), &ProxyAuth{"user", "pass"}),
+		WithStreamIsolation(),
+	)
+	require.NoError(t, err)
+	defer client.Shutdown()
+
+	req = <-requests
+	assert.NotEqual(t, "user", req.username)
+	assert.NotEmpty(t, req.password)
+}
@@ -0,0 +1,273 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// WithRawTransactions makes the client fetch raw transactions and decode
+// them locally instead of relying on verbose mode, which requires a bitcoind
+// with txindex behind the remote server.
+func WithRawTransactions() ClientOption {
+	return func(c *Client) {
+		c.rawTransactions = true
+	}
+}
+
+// DecodeTransaction decodes a raw transaction into the verbose format of
+// GetTransaction, without the block fields and confirmations. Output
+// addresses are encoded for params.
+func DecodeTransaction(
+	rawTx string,
+	params *chaincfg.Params,
+) (*GetTransactionResult, error) {
+	b, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &GetTransactionResult{
+		Hash:     msgTx.WitnessHash().String(),
+		Hex:      rawTx,
+		Locktime: msgTx.LockTime,
+		TxID:     msgTx.TxHash().String(),
+		Version:  uint32(msgTx.Version),
+		Vin:      make([]Vin, 0, len(msgTx.TxIn)),
+		Vout:     make([]Vout, 0, len(msgTx.TxOut)),
+	}
+	tx.setSize(&msgTx)
+
+	coinbase := blockchain.IsCoinBaseTx(&msgTx)
+	for _, txIn := range msgTx.TxIn {
+		vin := Vin{Sequence: txIn.Sequence}
+		if coinbase {
+			vin.Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		} else {
+			asm, _ := txscript.DisasmString(txIn.SignatureScript)
+			vin.ScriptSig = ScriptSig{
+				Asm: asm,
+				Hex: hex.EncodeToString(txIn.SignatureScript),
+			}
+			vin.TxID = txIn.PreviousOutPoint.Hash.String()
+			vin.Vout = txIn.PreviousOutPoint.Index
+		}
+
+		for _, item := range txIn.Witness {
+			vin.Witness = append(vin.Witness, hex.EncodeToString(item))
+		}
+
+		tx.Vin = append(tx.Vin, vin)
+	}
+
+	for i, txOut := range msgTx.TxOut {
+		tx.Vout = append(tx.Vout, Vout{
+			N:            uint32(i),
+			ScriptPubKey: decodeScriptPubKey(txOut.PkScript, params),
+			Value:        btcutil.Amount(txOut.Value),
+		})
+	}
+
+	return tx, nil
+}
+
+// decodeScriptPubKey describes an output script like bitcoind does, with an
+// address only for the script types that have one.
+func decodeScriptPubKey(script []byte, params *chaincfg.Params) ScriptPubKey {
+	asm, _ := txscript.DisasmString(script)
+	scriptPubKey := ScriptPubKey{
+		Asm:  asm,
+		Hex:  hex.EncodeToString(script),
+		Type: txscript.NonStandardTy.String(),
+	}
+
+	class, addresses, _, err := txscript.ExtractPkScriptAddrs(script, params)
+	if err != nil {
+		return scriptPubKey
+	}
+	scriptPubKey.Type = class.String()
+
+	switch class {
+	case txscript.PubKeyHashTy, txscript.ScriptHashTy,
+		txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy,
+		txscript.WitnessV1TaprootTy:
+		if len(addresses) == 1 {
+			scriptPubKey.Address = addresses[0].EncodeAddress()
+		}
+	}
+
+	return scriptPubKey
+}
+
+// decodeTransaction fetches and decodes a raw transaction. If height is
+// positive, or negative and found from the history of one of its outputs,
+// the transaction is proven at that height to fill its block fields and
+// confirmations.
+func (s *Client) decodeTransaction(
+	ctx context.Context,
+	txHash string,
+	height int64,
+) (*GetTransactionResult, error) {
+	rawTx, err := s.GetRawTransaction(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := DecodeTransaction(rawTx, s.Network())
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.TxID != txHash {
+		return nil, fmt.Errorf(
+			"server returned tx %s for %s",
+			tx.TxID,
+			txHash,
+		)
+	}
+
+	if height < 0 {
+		height, err = s.transactionHeight(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if height > 0 {
+		tip, err := s.tipHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.confirmTransaction(ctx, tx, height, tip)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tx, nil
+}
+
+// decodeTransactions fetches raw transactions in a single batch and decodes
+// them into txs.
+func (s *Client) decodeTransactions(
+	ctx context.Context,
+	txHashes []string,
+	txs map[string]*GetTransactionResult,
+) error {
+	batch := s.NewBatch()
+	calls := make([]*BatchCall[string], 0, len(txHashes))
+	for _, txHash := range txHashes {
+		calls = append(calls, batch.GetRawTransaction(txHash))
+	}
+
+	err := batch.Send(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, call := range calls {
+		rawTx, err := call.Result()
+		if err != nil {
+			return err
+		}
+
+		tx, err := DecodeTransaction(rawTx, s.Network())
+		if err != nil {
+			return err
+		}
+
+		if tx.TxID != txHashes[i] {
+			return fmt.Errorf(
+				"server returned tx %s for %s",
+				tx.TxID,
+				txHashes[i],
+			)
+		}
+
+		txs[txHashes[i]] = tx
+	}
+
+	return nil
+}
+
+// transactionHeight looks for the height of a transaction in the history of
+// its first spendable output whose history the server returns, 0 if it is
+// unconfirmed or has no such output. Callers knowing the height from a
+// history entry should pass it to decodeTransaction instead.
+func (s *Client) transactionHeight(
+	ctx context.Context,
+	tx *GetTransactionResult,
+) (int64, error) {
+	var tooLarge error
+	for _, vout := range tx.Vout {
+		script, err := hex.DecodeString(vout.ScriptPubKey.Hex)
+		if err != nil || txscript.IsUnspendable(script) {
+			continue
+		}
+
+		history, err := s.GetHistory(ctx, ScriptToElectrumScriptHash(script))
+		if errors.Is(err, ErrHistoryTooLarge) {
+			// Another output may have a shorter history.
+			tooLarge = err
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		for _, h := range history {
+			if h.Hash == tx.TxID && h.Height > 0 {
+				return h.Height, nil
+			}
+		}
+
+		return 0, nil
+	}
+
+	return 0, tooLarge
+}
+
+// confirmTransaction proves a decoded transaction is in the block at height,
+// then fills its block fields and confirmations relative to tip.
+func (s *Client) confirmTransaction(
+	ctx context.Context,
+	tx *GetTransactionResult,
+	height, tip int64,
+) error {
+	proof, err := s.GetMerkleProof(ctx, tx.TxID, uint32(height))
+	if err != nil {
+		return err
+	}
+
+	header, err := s.VerifyMerkleProof(ctx, tx.TxID, proof)
+	if err != nil {
+		return err
+	}
+
+	blockHeight := int64(proof.Height)
+	if blockHeight <= tip {
+		tx.Confirmations = int32(tip - blockHeight + 1)
+	}
+	tx.Blockhash = header.BlockHash().String()
+	tx.BlockHeight = blockHeight
+	tx.Blocktime = uint64(header.Timestamp.Unix())
+	tx.Time = tx.Blocktime
+	tx.Merkle = *proof
+
+	return nil
+}
@@ -0,0 +1,370 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serializeTx(t *testing.T, msgTx *wire.MsgTx) string {
+	var buf bytes.Buffer
+	require.NoError(t, msgTx.Serialize(&buf))
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	genesis := chaincfg.MainNetParams.GenesisBlock.Transactions[0]
+
+	tx, err := DecodeTransaction(
+		serializeTx(t, genesis),
+		&chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		tx.TxID,
+	)
+	assert.Equal(t, tx.TxID, tx.Hash)
+	assert.Equal(t, uint32(204), tx.Size)
+	assert.Equal(t, uint32(204), tx.Vsize)
+	assert.Equal(t, uint32(816), tx.Weight)
+	require.Len(t, tx.Vin, 1)
+	assert.NotEmpty(t, tx.Vin[0].Coinbase)
+	assert.Empty(t, tx.Vin[0].TxID)
+	require.Len(t, tx.Vout, 1)
+	assert.Equal(t, btcutil.Amount(50*btcutil.SatoshiPerBitcoin), tx.Vout[0].Value)
+	assert.Equal(t, "pubkey", tx.Vout[0].ScriptPubKey.Type)
+	assert.Empty(t, tx.Vout[0].ScriptPubKey.Address)
+
+	sized := &GetTransactionResult{Hex: tx.Hex}
+	require.NoError(t, sized.fillSize())
+	assert.Equal(t, uint32(204), sized.Vsize)
+	assert.Equal(t, uint32(816), sized.Weight)
+
+	address, err := btcutil.DecodeAddress(
+		"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		&chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(address)
+	require.NoError(t, err)
+
+	msgTx := wire.NewMsgTx(2)
+	msgTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}, Index: 3},
+		Witness:          wire.TxWitness{make([]byte, 71), make([]byte, 33)},
+		Sequence:         wire.MaxTxInSequenceNum - 2,
+	})
+	msgTx.AddTxOut(wire.NewTxOut(12345, pkScript))
+
+	tx, err = DecodeTransaction(serializeTx(t, msgTx), &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	assert.Equal(t, msgTx.TxHash().String(), tx.TxID)
+	assert.NotEqual(t, tx.TxID, tx.Hash)
+
+	weight := msgTx.SerializeSizeStripped()*3 + msgTx.SerializeSize()
+	assert.Equal(t, uint32(weight), tx.Weight)
+	assert.Equal(t, uint32((weight+3)/4), tx.Vsize)
+	assert.Equal(t, chainhash.Hash{1}.String(), tx.Vin[0].TxID)
+	assert.Equal(t, uint32(3), tx.Vin[0].Vout)
+	assert.Len(t, tx.Vin[0].Witness, 2)
+	assert.Equal(t, btcutil.Amount(12345), tx.Vout[0].Value)
+	assert.Equal(t, "witness_v0_keyhash", tx.Vout[0].ScriptPubKey.Type)
+	assert.Equal(t, address.EncodeAddress(), tx.Vout[0].ScriptPubKey.Address)
+}
+
+// testScript returns a P2WPKH output script told apart by id.
+func testScript(id byte) []byte {
+	return append([]byte{0x00, 0x14}, bytes.Repeat([]byte{id}, 20)...)
+}
+
+// newTestTx builds a transaction spending prevouts to outputs paying scripts.
+func newTestTx(prevouts []wire.OutPoint, scripts ...[]byte) *wire.MsgTx {
+	msgTx := wire.NewMsgTx(2)
+	for _, prevout := range prevouts {
+		msgTx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevout})
+	}
+	for _, script := range scripts {
+		msgTx.AddTxOut(wire.NewTxOut(1000, script))
+	}
+	return msgTx
+}
+
+// txServer is a fakeServer of transactions, each block holding at most one.
+// It serves raw transactions, the histories and mempools of the scripthashes
+// they pay or spend from, merkle proofs and headers.
+type txServer struct {
+	*fakeServer
+
+	txs       map[string]*wire.MsgTx
+	histories map[string][]*GetMempoolResult
+	blocks    map[int64]string
+	tooLarge  map[string]bool
+	tip       int64
+	// fork is the version of every header, changed to replace them all.
+	fork int32
+}
+
+func newTxServer(tip int64) *txServer {
+	s := &txServer{
+		fakeServer: newFakeServer(),
+		txs:        make(map[string]*wire.MsgTx),
+		histories:  make(map[string][]*GetMempoolResult),
+		blocks:     make(map[int64]string),
+		tooLarge:   make(map[string]bool),
+		tip:        tip,
+	}
+
+	s.on(
+		"blockchain.transaction.get",
+		func(params []interface{}) (interface{}, error) {
+			msgTx, ok := s.txs[params[0].(string)]
+			if !ok || params[1] == true {
+				return nil, &RPCError{
+					Code:    CodeDaemonError,
+					Message: "No such mempool or blockchain transaction",
+				}
+			}
+			var buf bytes.Buffer
+			_ = msgTx.Serialize(&buf)
+			return hex.EncodeToString(buf.Bytes()), nil
+		},
+	)
+	s.on(
+		"blockchain.scripthash.get_history",
+		func(params []interface{}) (interface{}, error) {
+			scripthash := params[0].(string)
+			if s.tooLarge[scripthash] {
+				return nil, &RPCError{
+					Code:    CodeInvalidParams,
+					Message: "history too large",
+				}
+			}
+			return append([]*GetMempoolResult{}, s.histories[scripthash]...), nil
+		},
+	)
+	s.on(
+		"blockchain.scripthash.get_mempool",
+		func(params []interface{}) (interface{}, error) {
+			mempool := []*GetMempoolResult{}
+			for _, entry := range s.histories[params[0].(string)] {
+				if entry.Height <= 0 {
+					mempool = append(mempool, entry)
+				}
+			}
+			return mempool, nil
+		},
+	)
+	s.on(
+		"blockchain.scripthash.subscribe",
+		func(params []interface{}) (interface{}, error) {
+			return s.status(params[0].(string)), nil
+		},
+	)
+	s.on(
+		"blockchain.transaction.get_merkle",
+		func(params []interface{}) (interface{}, error) {
+			return &GetMerkleProofResult{
+				Merkle: []string{},
+				Height: uint64(params[1].(float64)),
+			}, nil
+		},
+	)
+	s.on(
+		"blockchain.block.header",
+		func(params []interface{}) (interface{}, error) {
+			return s.headerHex(int64(params[0].(float64))), nil
+		},
+	)
+	s.on(
+		"blockchain.headers.subscribe",
+		func([]interface{}) (interface{}, error) {
+			return &SubscribeHeadersResult{
+				Height: s.tip,
+				Hex:    s.headerHex(s.tip),
+			}, nil
+		},
+	)
+
+	return s
+}
+
+// header returns the header of the block at height, whose merkle root is
+// the hash of its transaction.
+func (s *txServer) header(height int64) *wire.BlockHeader {
+	header := &wire.BlockHeader{
+		Version:   s.fork,
+		Timestamp: time.Unix(1600000000+height*600, 0),
+		Nonce:     uint32(height),
+	}
+	if txID, ok := s.blocks[height]; ok {
+		hash, _ := chainhash.NewHashFromStr(txID)
+		header.MerkleRoot = *hash
+	}
+	return header
+}
+
+func (s *txServer) headerHex(height int64) string {
+	var buf bytes.Buffer
+	_ = s.header(height).Serialize(&buf)
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// status returns the status of a scripthash, changing with its history.
+func (s *txServer) status(scripthash string) string {
+	b, _ := json.Marshal(s.histories[scripthash])
+	return chainhash.HashH(b).String()
+}
+
+// add puts msgTx in the block at height, or in the mempool with a height of
+// 0, replacing its previous place if any. It returns the txid.
+func (s *txServer) add(msgTx *wire.MsgTx, height int64) string {
+	txID := msgTx.TxHash().String()
+	s.remove(txID)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.txs[txID] = msgTx
+	if height > 0 {
+		s.blocks[height] = txID
+	}
+
+	scripts := make([][]byte, 0, len(msgTx.TxIn)+len(msgTx.TxOut))
+	for _, txIn := range msgTx.TxIn {
+		prevTx, ok := s.txs[txIn.PreviousOutPoint.Hash.String()]
+		if ok {
+			index := txIn.PreviousOutPoint.Index
+			scripts = append(scripts, prevTx.TxOut[index].PkScript)
+		}
+	}
+	for _, txOut := range msgTx.TxOut {
+		scripts = append(scripts, txOut.PkScript)
+	}
+
+	seen := make(map[string]bool)
+	for _, script := range scripts {
+		scripthash := ScriptToElectrumScriptHash(script)
+		if seen[scripthash] {
+			continue
+		}
+		seen[scripthash] = true
+		s.histories[scripthash] = append(
+			s.histories[scripthash],
+			&GetMempoolResult{Hash: txID, Height: height},
+		)
+	}
+
+	return txID
+}
+
+// remove drops a transaction from its block or the mempool.
+func (s *txServer) remove(txID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for height, blockTxID := range s.blocks {
+		if blockTxID == txID {
+			delete(s.blocks, height)
+		}
+	}
+
+	for scripthash, history := range s.histories {
+		kept := history[:0:0]
+		for _, entry := range history {
+			if entry.Hash != txID {
+				kept = append(kept, entry)
+			}
+		}
+		s.histories[scripthash] = kept
+	}
+}
+
+// notify pushes the new status of the scripthash of script.
+func (s *txServer) notify(script []byte) {
+	scripthash := ScriptToElectrumScriptHash(script)
+
+	s.lock.Lock()
+	status := s.status(scripthash)
+	s.lock.Unlock()
+
+	s.push("blockchain.scripthash.subscribe", scripthash, status)
+}
+
+// reorg replaces every block with one of another fork holding the same
+// transaction, without notifying any scripthash.
+func (s *txServer) reorg() {
+	s.lock.Lock()
+	s.fork++
+	s.lock.Unlock()
+}
+
+// mine sets the chain tip and notifies it.
+func (s *txServer) mine(tip int64) {
+	s.lock.Lock()
+	s.tip = tip
+	header := &SubscribeHeadersResult{Height: tip, Hex: s.headerHex(tip)}
+	s.lock.Unlock()
+
+	s.push("blockchain.headers.subscribe", header)
+}
+
+func TestRawTransactionHeight(t *testing.T) {
+	server := newTxServer(101)
+
+	funding := newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{9}}},
+		testScript(1),
+	)
+	server.add(funding, 90)
+
+	// The history of the first output is too large for the server.
+	msgTx := newTestTx(
+		[]wire.OutPoint{{Hash: funding.TxHash()}},
+		testScript(2),
+		testScript(3),
+	)
+	txID := server.add(msgTx, 100)
+	server.tooLarge[ScriptToElectrumScriptHash(testScript(2))] = true
+
+	ctx := context.Background()
+	client := newFakeClient(t, server.fakeServer, WithRawTransactions())
+
+	tx, err := client.GetTransaction(ctx, txID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), tx.BlockHeight)
+	assert.Equal(t, int32(2), tx.Confirmations)
+	assert.Equal(t, 2, server.calls("blockchain.scripthash.get_history"))
+
+	// Without block fields needed, the height is not looked up.
+	tx, err = client.getTransaction(ctx, funding.TxHash().String(), 0)
+	require.NoError(t, err)
+	assert.Zero(t, tx.Confirmations)
+	assert.Equal(t, 2, server.calls("blockchain.scripthash.get_history"))
+
+	// Transactions of a history are confirmed at their history height.
+	client = newFakeClient(t, server.fakeServer, WithRawTransactions())
+	history, err := client.DetailHistory(
+		ctx,
+		"",
+		[]*GetMempoolResult{{Hash: txID, Height: 100}},
+	)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, int64(100), history[0].BlockHeight)
+	assert.Equal(t, int32(2), history[0].Confirmations)
+	assert.Equal(t, btcutil.Amount(1000), history[0].InputsTotal)
+	assert.Equal(t, 2, server.calls("blockchain.scripthash.get_history"))
+}
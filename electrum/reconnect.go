@@ -0,0 +1,144 @@
+package electrum
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+type reconnectConfig struct {
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// WithReconnect makes the client redial the remote server when the transport
+// fails instead of shutting down. Attempts are spaced by an exponential backoff
+// starting at minBackoff and capped at maxBackoff; maxAttempts <= 0 retries forever.
+// Once reconnected, the last ServerVersion negotiation is replayed and all
+// active header, scripthash and masternode subscriptions are renewed, keeping
+// the channels handed to callers alive.
+func WithReconnect(
+	maxAttempts int,
+	minBackoff, maxBackoff time.Duration,
+) ClientOption {
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = defaultMaxBackoff
+		if maxBackoff < minBackoff {
+			maxBackoff = minBackoff
+		}
+	}
+
+	return func(c *Client) {
+		c.reconnect = &reconnectConfig{
+			maxAttempts: maxAttempts,
+			minBackoff:  minBackoff,
+			maxBackoff:  maxBackoff,
+		}
+	}
+}
+
+// redial tries to open a new transport until it succeeds, the attempts are
+// exhausted or the client is shut down.
+func (s *Client) redial() bool {
+	if s.dial == nil {
+		return false
+	}
+
+	backoff := s.reconnect.minBackoff
+	for attempt := 1; s.reconnect.maxAttempts <= 0 ||
+		attempt <= s.reconnect.maxAttempts; attempt++ {
+		select {
+		case <-s.quit:
+			return false
+		case <-time.After(backoff):
+		}
+
+		ctx, cancel := s.dialContext()
+		transport, err := s.dial(ctx)
+		cancel()
+		if err == nil {
+			s.setTransport(transport)
+			s.logger.Infof("Reconnected after %d attempt(s)", attempt)
+			return true
+		}
+
+		s.logger.Warnf("Reconnect attempt %d failed: %v", attempt, err)
+
+		backoff *= 2
+		if backoff > s.reconnect.maxBackoff {
+			backoff = s.reconnect.maxBackoff
+		}
+	}
+
+	s.logger.Errorf(
+		"Giving up reconnecting after %d attempts",
+		s.reconnect.maxAttempts,
+	)
+	return false
+}
+
+// restore replays the protocol negotiation and the active subscriptions on a
+// freshly reconnected transport. If any of them fails, the transport is
+// closed to reconnect again, rather than keeping a connection on which
+// subscriptions are silently dead.
+func (s *Client) restore() {
+	s.versionLock.RLock()
+	version := s.version
+	s.versionLock.RUnlock()
+
+	if version[0] != "" {
+		ctx, cancel := s.dialContext()
+		_, _, err := s.ServerVersion(ctx, version[0], version[1])
+		cancel()
+		if err != nil {
+			s.logger.Errorf("Renegotiate server version failed: %v", err)
+			s.closeTransport()
+			return
+		}
+	}
+
+	s.resubscribersLock.Lock()
+	resubscribers := make([]func(context.Context) error, len(s.resubscribers))
+	copy(resubscribers, s.resubscribers)
+	s.resubscribersLock.Unlock()
+
+	for _, resubscribe := range resubscribers {
+		ctx, cancel := s.dialContext()
+		err := resubscribe(ctx)
+		cancel()
+		if err != nil {
+			s.logger.Errorf("Resubscribe failed: %v", err)
+			s.closeTransport()
+			return
+		}
+	}
+}
+
+// closeTransport closes the current transport, making the listener reconnect.
+func (s *Client) closeTransport() {
+	if transport := s.getTransport(); transport != nil {
+		_ = transport.Close()
+	}
+}
+
+// addResubscriber registers fn to be called after every reconnection.
+func (s *Client) addResubscriber(fn func(context.Context) error) {
+	s.resubscribersLock.Lock()
+	s.resubscribers = append(s.resubscribers, fn)
+	s.resubscribersLock.Unlock()
+}
+
+func (s *Client) dialContext() (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(context.Background(), s.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
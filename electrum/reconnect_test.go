@@ -0,0 +1,92 @@
+package electrum
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnect(t *testing.T) {
+	server := newFakeServer()
+	versions := 0
+	server.on("server.version", func([]interface{}) (interface{}, error) {
+		versions++
+		// The renegotiation on the first reconnection fails.
+		if versions == 2 {
+			return nil, &RPCError{Code: CodeBadRequest, Message: "busy"}
+		}
+		return []string{"fake 1.0", "1.4"}, nil
+	})
+	server.result(
+		"blockchain.headers.subscribe",
+		&SubscribeHeadersResult{Height: 100, Hex: "00"},
+	)
+	server.result("blockchain.scripthash.subscribe", "status")
+	server.on(
+		"blockchain.scripthash.get_balance",
+		func([]interface{}) (interface{}, error) {
+			return nil, errNoAnswer
+		},
+	)
+
+	client := newFakeClient(
+		t,
+		server,
+		WithReconnect(0, time.Millisecond, 10*time.Millisecond),
+	)
+
+	ctx := context.Background()
+	_, _, err := client.ServerVersion(ctx, "test", "1.4")
+	require.NoError(t, err)
+
+	headers, err := client.SubscribeHeaders(ctx)
+	require.NoError(t, err)
+	<-headers
+
+	sub, notifs := client.SubscribeScripthash()
+	require.NoError(t, sub.Add(ctx, "scripthash"))
+	<-notifs
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.GetBalance(ctx, "scripthash")
+		errs <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return server.calls("blockchain.scripthash.get_balance") == 1
+	}, time.Second, time.Millisecond)
+
+	server.transport().fail(io.ErrUnexpectedEOF)
+
+	// The pending request fails with the transport error.
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	case <-time.After(time.Second):
+		t.Fatal("pending request not failed")
+	}
+
+	// Subscriptions are renewed on the same channels, once the version is
+	// negotiated again on a third connection.
+	select {
+	case header := <-headers:
+		assert.Equal(t, int64(100), header.Height)
+	case <-time.After(time.Second):
+		t.Fatal("headers not resubscribed")
+	}
+
+	select {
+	case notif := <-notifs:
+		assert.Equal(t, "scripthash", notif.Params[0])
+	case <-time.After(time.Second):
+		t.Fatal("scripthash not resubscribed")
+	}
+
+	assert.Equal(t, 3, server.dials())
+	assert.Equal(t, 3, versions)
+}
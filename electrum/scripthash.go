@@ -2,8 +2,10 @@ package electrum
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -14,8 +16,8 @@ type GetBalanceResp struct {
 
 // GetBalanceResult represents the content of the result field in the response to GetBalance().
 type GetBalanceResult struct {
-	Confirmed   float64 `json:"confirmed"`
-	Unconfirmed float64 `json:"unconfirmed"`
+	Confirmed   btcutil.Amount `json:"confirmed"`
+	Unconfirmed btcutil.Amount `json:"unconfirmed"`
 }
 
 // GetBalance returns the confirmed and unconfirmed balance for a scripthash.
@@ -47,16 +49,18 @@ type GetMempoolResp struct {
 // GetMempoolResult represents the content of the result field in the response
 // to GetHistory() and GetMempool().
 type GetMempoolResult struct {
-	Hash   string `json:"tx_hash"`
-	Height int64  `json:"height"`
-	Fee    uint32 `json:"fee,omitempty"`
+	Hash   string         `json:"tx_hash"`
+	Height int64          `json:"height"`
+	Fee    btcutil.Amount `json:"fee,omitempty"`
 }
 
+// DetailedMempoolResult is a history entry with its detailed transaction,
+// including its size, fee rate and replaceability.
 type DetailedMempoolResult struct {
 	*DetailedTransaction
-	Height   int64  `json:"height"`
-	Fee      uint32 `json:"fee,omitempty"`
-	Incoming bool   `json:"incoming,omitempty"`
+	Height   int64          `json:"height"`
+	Fee      btcutil.Amount `json:"fee,omitempty"`
+	Incoming bool           `json:"incoming,omitempty"`
 }
 
 // GetHistory returns the confirmed and unconfirmed history for a scripthash.
@@ -87,15 +91,41 @@ func (s *Client) DetailHistory(
 ) ([]*DetailedMempoolResult, error) {
 	var result []*DetailedMempoolResult
 
+	txHashes := make([]string, 0, len(history))
+	for _, h := range history {
+		txHashes = append(txHashes, h.Hash)
+	}
+
+	txs, err := s.getTransactions(ctx, txHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoded transactions are confirmed at their history height.
+	var tip int64
+	if s.rawTransactions {
+		tip, err = s.tipHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.SetLimit(10)
 	mtx := sync.Mutex{}
 	for _, h := range history {
 		func(h *GetMempoolResult) {
 			eg.Go(func() error {
-				tx, err := s.GetTransaction(ctx, h.Hash)
-				if err != nil {
-					return err
+				tx := txs[h.Hash]
+				if tx == nil {
+					return fmt.Errorf("tx %s not found", h.Hash)
+				}
+				if s.rawTransactions && h.Height > 0 &&
+					tx.Confirmations == 0 {
+					err := s.confirmTransaction(ctx, tx, h.Height, tip)
+					if err != nil {
+						return err
+					}
 				}
 				s.logger.Debugf("detailing tx: %s", tx.TxID)
 				detailedTx, err := s.DetailTransaction(ctx, tx)
@@ -164,10 +194,10 @@ type ListUnspentResp struct {
 
 // ListUnspentResult represents the content of the result field in the response to ListUnspent()
 type ListUnspentResult struct {
-	Height   uint64 `json:"height"`
-	Position uint32 `json:"tx_pos"`
-	Hash     string `json:"tx_hash"`
-	Value    uint64 `json:"value"`
+	Height   uint64         `json:"height"`
+	Position uint32         `json:"tx_pos"`
+	Hash     string         `json:"tx_hash"`
+	Value    btcutil.Amount `json:"value"`
 }
 
 // ListUnspent returns an ordered list of UTXOs for a scripthash.
@@ -124,6 +124,10 @@ func (s *Client) ServerVersion(
 	} else {
 		serverVer = resp.Result[0]
 		protocolVer = resp.Result[1]
+
+		s.versionLock.Lock()
+		s.version = [2]string{clientVersion, protocolVersion}
+		s.versionLock.Unlock()
 	}
 
 	return
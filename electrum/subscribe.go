@@ -40,11 +40,38 @@ func (s *Client) SubscribeHeaders(
 		return nil, err
 	}
 
+	if resp.Result != nil {
+		s.setTip(resp.Result.Height)
+	}
+
 	respChan := make(chan *SubscribeHeadersResult, 1)
 	respChan <- resp.Result
 
+	s.addResubscriber(func(ctx context.Context) error {
+		var resp SubscribeHeadersResp
+
+		err := s.request(
+			ctx,
+			"blockchain.headers.subscribe",
+			[]interface{}{},
+			&resp,
+		)
+		if err != nil {
+			return err
+		}
+
+		if resp.Result != nil {
+			s.setTip(resp.Result.Height)
+			respChan <- resp.Result
+		}
+
+		return nil
+	})
+
+	// Registered before returning, not to miss the first notifications.
+	notifs := s.listenPush("blockchain.headers.subscribe")
 	go func() {
-		for msg := range s.listenPush("blockchain.headers.subscribe") {
+		for msg := range notifs {
 			if msg.err != nil {
 				return
 			}
@@ -57,6 +84,7 @@ func (s *Client) SubscribeHeaders(
 			}
 
 			for _, param := range resp.Params {
+				s.setTip(param.Height)
 				respChan <- param
 			}
 		}
@@ -89,8 +117,9 @@ func (s *Client) SubscribeScripthash() (*ScripthashSubscription, <-chan *Subscri
 		scripthashMap: make(map[string]string),
 	}
 
+	notifs := s.listenPush("blockchain.scripthash.subscribe")
 	go func() {
-		for msg := range s.listenPush("blockchain.scripthash.subscribe") {
+		for msg := range notifs {
 			if msg.err != nil {
 				return
 			}
@@ -113,6 +142,8 @@ func (s *Client) SubscribeScripthash() (*ScripthashSubscription, <-chan *Subscri
 		}
 	}()
 
+	s.addResubscriber(sub.Resubscribe)
+
 	return sub, sub.notifChan
 }
 
@@ -121,6 +152,25 @@ func (sub *ScripthashSubscription) Add(
 	ctx context.Context,
 	scripthash string,
 	address ...string,
+) error {
+	err := sub.subscribe(ctx, scripthash)
+	if err != nil {
+		return err
+	}
+
+	sub.lock.Lock()
+	sub.subscribedSH = append(sub.subscribedSH[:], scripthash)
+	if len(address) > 0 {
+		sub.scripthashMap[scripthash] = address[0]
+	}
+	sub.lock.Unlock()
+
+	return nil
+}
+
+func (sub *ScripthashSubscription) subscribe(
+	ctx context.Context,
+	scripthash string,
 ) error {
 	var resp basicResp
 
@@ -138,13 +188,6 @@ func (sub *ScripthashSubscription) Add(
 		sub.notifChan <- &SubscribeNotif{[2]string{scripthash, resp.Result}}
 	}
 
-	sub.lock.Lock()
-	sub.subscribedSH = append(sub.subscribedSH[:], scripthash)
-	if len(address) > 0 {
-		sub.scripthashMap[scripthash] = address[0]
-	}
-	sub.lock.Unlock()
-
 	return nil
 }
 
@@ -224,10 +267,15 @@ func (sub *ScripthashSubscription) RemoveAddress(address string) error {
 	return errors.New("scripthash not found")
 }
 
-// Resubscribe ...
+// Resubscribe renews the subscription of every added scripthash on the server.
 func (sub *ScripthashSubscription) Resubscribe(ctx context.Context) error {
-	for _, v := range sub.subscribedSH {
-		err := sub.Add(ctx, v)
+	sub.lock.RLock()
+	subscribedSH := make([]string, len(sub.subscribedSH))
+	copy(subscribedSH, sub.subscribedSH)
+	sub.lock.RUnlock()
+
+	for _, v := range subscribedSH {
+		err := sub.subscribe(ctx, v)
 		if err != nil {
 			return err
 		}
@@ -259,8 +307,29 @@ func (s *Client) SubscribeMasternode(
 		respChan <- resp.Result
 	}
 
+	s.addResubscriber(func(ctx context.Context) error {
+		var resp basicResp
+
+		err := s.request(
+			ctx,
+			"blockchain.masternode.subscribe",
+			[]interface{}{collateral},
+			&resp,
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(resp.Result) > 0 {
+			respChan <- resp.Result
+		}
+
+		return nil
+	})
+
+	notifs := s.listenPush("blockchain.masternode.subscribe")
 	go func() {
-		for msg := range s.listenPush("blockchain.masternode.subscribe") {
+		for msg := range notifs {
 			if msg.err != nil {
 				return
 			}
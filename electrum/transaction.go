@@ -2,9 +2,9 @@ package electrum
 
 import (
 	"context"
-	"sync"
+	"fmt"
 
-	"golang.org/x/sync/errgroup"
+	"github.com/btcsuite/btcd/btcutil"
 )
 
 // BroadcastTransaction sends a raw transaction to the remote server to
@@ -36,12 +36,15 @@ type GetTransactionResp struct {
 // GetTransactionResult represents the content of the result field in the response to GetTransaction().
 type GetTransactionResult struct {
 	Blockhash     string               `json:"blockhash"`
+	BlockHeight   int64                `json:"blockheight,omitempty"` // Filled by the client once cached.
 	Blocktime     uint64               `json:"blocktime"`
 	Confirmations int32                `json:"confirmations"`
 	Hash          string               `json:"hash"`
 	Hex           string               `json:"hex"`
 	Locktime      uint32               `json:"locktime"`
 	Size          uint32               `json:"size"`
+	Vsize         uint32               `json:"vsize"`
+	Weight        uint32               `json:"weight"`
 	Time          uint64               `json:"time"`
 	TxID          string               `json:"txid"`
 	Version       uint32               `json:"version"`
@@ -53,9 +56,13 @@ type GetTransactionResult struct {
 type DetailedTransaction struct {
 	*GetTransactionResult
 	Vin          []VinWithPrevout `json:"vin"`
-	InputsTotal  float64          `json:"inputs_total"`
-	OutputsTotal float64          `json:"outputs_total"`
-	FeeInSat     float64          `json:"fee_in_sat"`
+	InputsTotal  btcutil.Amount   `json:"inputs_total"`
+	OutputsTotal btcutil.Amount   `json:"outputs_total"`
+	FeeInSat     btcutil.Amount   `json:"fee_in_sat"`
+	// FeeRate is the fee rate in sat/vB.
+	FeeRate float64 `json:"fee_rate"`
+	// RBF tells whether the transaction signals BIP125 replaceability.
+	RBF bool `json:"rbf"`
 }
 
 // Vin represents the input side of a transaction.
@@ -65,11 +72,23 @@ type Vin struct {
 	Sequence  uint32    `json:"sequence"`
 	TxID      string    `json:"txid"`
 	Vout      uint32    `json:"vout"`
+	Witness   []string  `json:"txinwitness,omitempty"`
 }
 
+// VinWithPrevout is a transaction input with the output it spends, nil for
+// coinbase inputs, and its address and value in satoshis.
 type VinWithPrevout struct {
 	*Vin
-	Prevout *Vout `json:"prevout"`
+	Index   uint32         `json:"index"`
+	Prevout *Vout          `json:"prevout"`
+	Address string         `json:"address,omitempty"`
+	Value   btcutil.Amount `json:"value_sat"`
+}
+
+// IsCoinbase reports whether the input is the coinbase of a block, which
+// spends no previous output.
+func (v *Vin) IsCoinbase() bool {
+	return v.Coinbase != ""
 }
 
 // ScriptSig represents the signature script for that transaction input.
@@ -78,11 +97,12 @@ type ScriptSig struct {
 	Hex string `json:"hex"`
 }
 
-// Vout represents the output side of a transaction.
+// Vout represents the output side of a transaction. Value is encoded in BTC
+// in JSON.
 type Vout struct {
-	N            uint32       `json:"n"`
-	ScriptPubKey ScriptPubKey `json:"scriptPubKey"`
-	Value        float64      `json:"value"`
+	N            uint32         `json:"n"`
+	ScriptPubKey ScriptPubKey   `json:"scriptPubKey"`
+	Value        btcutil.Amount `json:"value"`
 }
 
 // ScriptPubKey represents the script of that transaction output.
@@ -95,20 +115,44 @@ type ScriptPubKey struct {
 	Type      string   `json:"type"`
 }
 
-// GetTransaction gets the detailed information for a transaction.
+// GetTransaction gets the detailed information for a transaction. With
+// WithRawTransactions, it is decoded locally and its height looked up in the
+// history of its first spendable output.
 // https://electrumx.readthedocs.io/en/latest/protocol-methods.html#blockchain-transaction-get
 func (s *Client) GetTransaction(
 	ctx context.Context,
 	txHash string,
+) (*GetTransactionResult, error) {
+	return s.getTransaction(ctx, txHash, -1)
+}
+
+// getTransaction gets a transaction like GetTransaction. With
+// WithRawTransactions, height is the block height of the transaction if the
+// caller knows it, 0 if its block fields are not needed, or negative to look
+// it up.
+func (s *Client) getTransaction(
+	ctx context.Context,
+	txHash string,
+	height int64,
 ) (*GetTransactionResult, error) {
 	var resp GetTransactionResp
 	var tx GetTransactionResult
 
-	if ok := s.txCache.Load(txHash, &tx); ok {
+	if ok := s.loadCachedTx(ctx, txHash, &tx); ok {
 		s.logger.Debugf("Tx %s found in cache", txHash)
 		return &tx, nil
 	}
 
+	if s.rawTransactions {
+		tx, err := s.decodeTransaction(ctx, txHash, height)
+		if err != nil {
+			return nil, err
+		}
+
+		s.storeCachedTx(ctx, txHash, tx)
+		return tx, nil
+	}
+
 	err := s.request(
 		ctx,
 		"blockchain.transaction.get",
@@ -119,14 +163,79 @@ func (s *Client) GetTransaction(
 		return nil, err
 	}
 
-	if resp.Result != nil && resp.Result.Confirmations > 6 {
-		err := s.txCache.Store(txHash, *resp.Result)
+	if resp.Result != nil {
+		s.storeCachedTx(ctx, txHash, resp.Result)
+	}
+
+	return resp.Result, nil
+}
+
+// getTransactions gets several transactions at once, from the cache or in
+// batches from the remote server. With WithRawTransactions, transactions are
+// decoded without block fields and confirmations.
+func (s *Client) getTransactions(
+	ctx context.Context,
+	txHashes []string,
+) (map[string]*GetTransactionResult, error) {
+	txs := make(map[string]*GetTransactionResult, len(txHashes))
+
+	var missing []string
+	for _, txHash := range txHashes {
+		if _, ok := txs[txHash]; ok {
+			continue
+		}
+
+		var tx GetTransactionResult
+		if ok := s.loadCachedTx(ctx, txHash, &tx); ok {
+			s.logger.Debugf("Tx %s found in cache", txHash)
+			txs[txHash] = &tx
+			continue
+		}
+
+		txs[txHash] = nil
+		missing = append(missing, txHash)
+	}
+
+	for start := 0; start < len(missing); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		if s.rawTransactions {
+			err := s.decodeTransactions(ctx, missing[start:end], txs)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		batch := s.NewBatch()
+		calls := make([]*BatchCall[*GetTransactionResult], 0, end-start)
+		for _, txHash := range missing[start:end] {
+			calls = append(calls, batch.GetTransaction(txHash))
+		}
+
+		err := batch.Send(ctx)
 		if err != nil {
-			s.logger.Errorf("Store tx %s in cache failed: %v", txHash, err)
+			return nil, err
+		}
+
+		for i, call := range calls {
+			tx, err := call.Result()
+			if err != nil {
+				return nil, err
+			}
+
+			txHash := missing[start+i]
+			if tx != nil {
+				s.storeCachedTx(ctx, txHash, tx)
+			}
+			txs[txHash] = tx
 		}
 	}
 
-	return resp.Result, nil
+	return txs, nil
 }
 
 // GetRawTransaction gets a raw encoded transaction.
@@ -164,7 +273,8 @@ func (s *Client) GetTransactionOutput(
 	return &tx.Vout[outputIndex], nil
 }
 
-// Details a transaction by adding Prevout to Vin.
+// Details a transaction by adding Prevout to Vin. Inputs keep their order
+// in the transaction.
 func (s *Client) DetailTransaction(
 	ctx context.Context,
 	tx *GetTransactionResult,
@@ -177,46 +287,64 @@ func (s *Client) DetailTransaction(
 		FeeInSat:             0,
 	}
 
-	if ok := s.txCache.Load(tx.TxID, &detailedTx); ok {
+	var cachedTx DetailedTransaction
+	if ok := s.loadCachedTx(ctx, tx.TxID, &cachedTx); ok {
 		s.logger.Debugf("DetailedTx %s found in cache", tx.TxID)
-		return &detailedTx, nil
+		return &cachedTx, nil
 	}
 
-	mtx := sync.Mutex{}
-	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(20)
+	txHashes := make([]string, 0, len(tx.Vin))
 	for _, vin := range tx.Vin {
+		if !vin.IsCoinbase() {
+			txHashes = append(txHashes, vin.TxID)
+		}
+	}
+
+	prevTxs, err := s.getTransactions(ctx, txHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	coinbase := false
+	for i, vin := range tx.Vin {
 		vin := vin // copy vin
-		eg.Go(func() error {
-			prevout, err := s.GetTransactionOutput(
-				ctx,
+		if vin.IsCoinbase() {
+			coinbase = true
+			detailedTx.Vin = append(
+				detailedTx.Vin,
+				VinWithPrevout{Vin: &vin, Index: uint32(i)},
+			)
+			continue
+		}
+
+		prevTx := prevTxs[vin.TxID]
+		if prevTx == nil || int(vin.Vout) >= len(prevTx.Vout) {
+			return nil, fmt.Errorf(
+				"prevout %s:%d of tx %s not found",
 				vin.TxID,
 				vin.Vout,
-			)
-			if err != nil {
-				return err
-			}
-			s.logger.Debugf(
-				"from tx %s vin.tx %s prevout address & value: %v %f",
 				tx.TxID,
-				vin.TxID,
-				getAddressFromVout(*prevout),
-				prevout.Value,
 			)
-			mtx.Lock()
-			defer mtx.Unlock()
-			detailedTx.Vin = append(
-				detailedTx.Vin,
-				VinWithPrevout{
-					Vin:     &vin,
-					Prevout: prevout,
-				},
-			)
-			return nil
-		})
-	}
-	if err := eg.Wait(); err != nil {
-		return nil, err
+		}
+		prevout := &prevTx.Vout[vin.Vout]
+
+		s.logger.Debugf(
+			"from tx %s vin.tx %s prevout address & value: %v %v",
+			tx.TxID,
+			vin.TxID,
+			getAddressFromVout(*prevout),
+			prevout.Value,
+		)
+		detailedTx.Vin = append(
+			detailedTx.Vin,
+			VinWithPrevout{
+				Vin:     &vin,
+				Index:   uint32(i),
+				Prevout: prevout,
+				Address: getAddressFromVout(*prevout),
+				Value:   prevout.Value,
+			},
+		)
 	}
 
 	// calculate inputsTotal, outputsTotal, feeInSat
@@ -224,14 +352,21 @@ func (s *Client) DetailTransaction(
 		detailedTx.OutputsTotal += vout.Value
 	}
 	for _, vin := range detailedTx.Vin {
-		detailedTx.InputsTotal += vin.Prevout.Value
+		detailedTx.InputsTotal += vin.Value
+	}
+	// Coinbase transactions create their outputs and pay no fee.
+	if !coinbase {
+		detailedTx.FeeInSat = detailedTx.InputsTotal - detailedTx.OutputsTotal
 	}
-	detailedTx.FeeInSat = detailedTx.InputsTotal - detailedTx.OutputsTotal
 
-	err := s.txCache.Store(tx.TxID, detailedTx)
+	err = tx.fillSize()
 	if err != nil {
-		s.logger.Errorf("Store detailedTx %s in cache failed: %v", tx.TxID, err)
+		return nil, err
 	}
+	detailedTx.FeeRate = feeRate(detailedTx.FeeInSat, tx.Vsize)
+	detailedTx.RBF = tx.SignalsRBF()
+
+	s.storeCachedTx(ctx, tx.TxID, &detailedTx)
 
 	return &detailedTx, nil
 }
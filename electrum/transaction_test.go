@@ -0,0 +1,82 @@
+package electrum
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetailTransaction(t *testing.T) {
+	ctx := context.Background()
+	client := &Client{
+		txCache: NewMemoryTxCache(100),
+		logger:  newLogger(),
+	}
+	client.setTip(1000)
+
+	coinbase, err := client.DetailTransaction(ctx, &GetTransactionResult{
+		TxID: "coinbase",
+		Vin:  []Vin{{Coinbase: "03a0860100", Sequence: 0}},
+		Vout: []Vout{{Value: 625000000}},
+	})
+	require.NoError(t, err)
+	require.Len(t, coinbase.Vin, 1)
+	assert.Nil(t, coinbase.Vin[0].Prevout)
+	assert.Equal(t, btcutil.Amount(0), coinbase.FeeInSat)
+	assert.False(t, coinbase.RBF)
+
+	// Deep enough prevouts are served by the cache without reorg checks.
+	var vins []Vin
+	for i := 0; i < 5; i++ {
+		txID := fmt.Sprintf("prev%d", i)
+		client.storeCachedTx(ctx, txID, &GetTransactionResult{
+			TxID:          txID,
+			Blockhash:     "00",
+			BlockHeight:   1,
+			Confirmations: 1000,
+			Vout: []Vout{
+				{N: 0, Value: 1},
+				{
+					N:            1,
+					Value:        btcutil.Amount(1000 * (i + 1)),
+					ScriptPubKey: ScriptPubKey{Address: txID},
+				},
+			},
+		})
+		vins = append(
+			vins,
+			Vin{TxID: txID, Vout: 1, Sequence: wire.MaxTxInSequenceNum},
+		)
+	}
+	vins[2].Sequence = wire.MaxTxInSequenceNum - 2
+
+	// Inputs in reverse order of their prevout ids.
+	for i, j := 0, len(vins)-1; i < j; i, j = i+1, j-1 {
+		vins[i], vins[j] = vins[j], vins[i]
+	}
+
+	tx, err := client.DetailTransaction(ctx, &GetTransactionResult{
+		TxID:   "tx",
+		Vin:    vins,
+		Vout:   []Vout{{Value: 14000}},
+		Vsize:  100,
+		Weight: 400,
+	})
+	require.NoError(t, err)
+	require.Len(t, tx.Vin, len(vins))
+	for i, vin := range tx.Vin {
+		assert.Equal(t, uint32(i), vin.Index)
+		assert.Equal(t, vins[i].TxID, vin.TxID)
+		assert.Equal(t, vins[i].TxID, vin.Address)
+		assert.Equal(t, vin.Prevout.Value, vin.Value)
+	}
+	assert.Equal(t, btcutil.Amount(15000), tx.InputsTotal)
+	assert.Equal(t, btcutil.Amount(1000), tx.FeeInSat)
+	assert.Equal(t, 10.0, tx.FeeRate)
+	assert.True(t, tx.RBF)
+}
@@ -32,18 +32,41 @@ func withOptions(opts map[string]interface{}) []DialerOption {
 	return options
 }
 
+// newDialer returns a direct dialer configured by options.
+func newDialer(options []DialerOption) *Dialer {
+	d := &Dialer{}
+
+	for _, option := range options {
+		option(&d.Dialer)
+	}
+
+	return d
+}
+
 // NewTCPTransport opens a new TCP connection to the remote server.
 func NewTCPTransport(
 	ctx context.Context,
 	addr string,
 	options ...DialerOption,
 ) (*TCPTransport, error) {
-	var d = net.Dialer{}
+	return newDialer(options).DialTCP(ctx, addr)
+}
 
-	for _, option := range options {
-		option(&d)
-	}
+// NewSSLTransport opens a new SSL connection to the remote server.
+func NewSSLTransport(
+	ctx context.Context,
+	addr string,
+	config *tls.Config,
+	options ...DialerOption,
+) (*TCPTransport, error) {
+	return newDialer(options).DialSSL(ctx, addr, config)
+}
 
+// DialTCP opens a new TCP connection to the remote server.
+func (d *Dialer) DialTCP(
+	ctx context.Context,
+	addr string,
+) (*TCPTransport, error) {
 	conn, err := d.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return nil, err
@@ -52,7 +75,7 @@ func NewTCPTransport(
 	tcp := &TCPTransport{
 		conn:      conn,
 		responses: make(chan []byte),
-		errors:    make(chan error),
+		errors:    make(chan error, 1),
 	}
 
 	go tcp.listen()
@@ -60,29 +83,42 @@ func NewTCPTransport(
 	return tcp, nil
 }
 
-// NewSSLTransport opens a new SSL connection to the remote server.
-func NewSSLTransport(
+// DialSSL opens a new SSL connection to the remote server.
+func (d *Dialer) DialSSL(
 	ctx context.Context,
 	addr string,
 	config *tls.Config,
-	options ...DialerOption,
 ) (*TCPTransport, error) {
-	dialer := tls.Dialer{
-		NetDialer: &net.Dialer{},
-		Config:    config,
+	rawConn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
 	}
-	for _, option := range options {
-		option(dialer.NetDialer)
+
+	// As tls.Dialer, verify the certificate against the host of addr unless
+	// the config names another server.
+	if config == nil {
+		config = &tls.Config{}
 	}
-	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	conn := tls.Client(rawConn, config)
+	err = conn.HandshakeContext(ctx)
 	if err != nil {
+		rawConn.Close()
 		return nil, err
 	}
 
 	tcp := &TCPTransport{
 		conn:      conn,
 		responses: make(chan []byte),
-		errors:    make(chan error),
+		errors:    make(chan error, 1),
 	}
 
 	go tcp.listen()
@@ -17,6 +17,16 @@ func mustMarshalJSON(v interface{}) string {
 	return string(b[:])
 }
 
+// ReverseBytes returns a reversed copy of b, to switch between the internal
+// byte order of hashes and the one they are displayed in.
+func ReverseBytes(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i, v := range b {
+		reversed[len(b)-1-i] = v
+	}
+	return reversed
+}
+
 type TxCache struct {
 	mu sync.Mutex
 	db *sql.DB
@@ -70,14 +80,13 @@ func (c *TxCache) Store(txID string, tx any) error {
 
 	_, err = c.db.Exec(
 		`INSERT INTO tx_cache (txid, tx, is_detailed) VALUES (?, ?, ?)
-		ON CONFLICT(txid) WHERE is_detailed = 0 DO UPDATE SET
-			tx = ?,
-			is_detailed = ?`,
+		ON CONFLICT(txid) DO UPDATE SET
+			tx = excluded.tx,
+			is_detailed = excluded.is_detailed
+		WHERE is_detailed = 0 OR excluded.is_detailed = 1`,
 		txID,
 		string(b[:]),
 		isDetailed,
-		string(b[:]),
-		isDetailed,
 	)
 	if err != nil {
 		return err
@@ -86,6 +95,14 @@ func (c *TxCache) Store(txID string, tx any) error {
 	return nil
 }
 
+func (c *TxCache) Delete(txID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.db.Exec("DELETE FROM tx_cache WHERE txid = ?", txID)
+	return err
+}
+
 func (c *TxCache) Load(txID string, tx any) (ok bool) {
 	c.mu.Lock()
 
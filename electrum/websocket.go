@@ -0,0 +1,133 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsCloseTimeout bounds the time spent sending a close frame on Close.
+const wsCloseTimeout = time.Second
+
+// WSTransport store information about the WebSocket transport. Each
+// JSON-RPC message, or batch, is framed in its own text message.
+type WSTransport struct {
+	conn      *websocket.Conn
+	responses chan []byte
+	errors    chan error
+
+	writeLock sync.Mutex
+}
+
+// NewWSTransport opens a new WebSocket connection to the remote server at
+// url, either ws:// or wss:// with config.
+func NewWSTransport(
+	ctx context.Context,
+	url string,
+	config *tls.Config,
+	options ...DialerOption,
+) (*WSTransport, error) {
+	return newDialer(options).DialWS(ctx, url, config)
+}
+
+// DialWS opens a new WebSocket connection to the remote server at url,
+// either ws:// or wss:// with config.
+func (d *Dialer) DialWS(
+	ctx context.Context,
+	url string,
+	config *tls.Config,
+) (*WSTransport, error) {
+	dialer := websocket.Dialer{
+		NetDialContext:   d.DialContext,
+		TLSClientConfig:  config,
+		HandshakeTimeout: d.Timeout,
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	ws := &WSTransport{
+		conn:      conn,
+		responses: make(chan []byte),
+		errors:    make(chan error, 1),
+	}
+
+	go ws.listen()
+
+	return ws, nil
+}
+
+func (t *WSTransport) listen() {
+	defer t.conn.Close()
+
+	for {
+		// Close frames are returned as a *websocket.CloseError.
+		_, msg, err := t.conn.ReadMessage()
+		if err != nil {
+			t.errors <- err
+			break
+		}
+		if DebugMode {
+			log.Printf(
+				"%s [debug] %s -> %s",
+				time.Now().Format("2006-01-02 15:04:05"),
+				t.conn.RemoteAddr(),
+				msg,
+			)
+		}
+
+		t.responses <- msg
+	}
+}
+
+// SendMessage sends a message to the remote server through the WebSocket
+// transport.
+func (t *WSTransport) SendMessage(body []byte) error {
+	if DebugMode {
+		log.Printf(
+			"%s [debug] %s <- %s",
+			time.Now().Format("2006-01-02 15:04:05"),
+			t.conn.RemoteAddr(),
+			body,
+		)
+	}
+
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+
+	return t.conn.WriteMessage(
+		websocket.TextMessage,
+		bytes.TrimRight(body, string(nl)),
+	)
+}
+
+// Responses returns chan to WebSocket transport responses.
+func (t *WSTransport) Responses() <-chan []byte {
+	return t.responses
+}
+
+// Errors returns chan to WebSocket transport errors.
+func (t *WSTransport) Errors() <-chan error {
+	return t.errors
+}
+
+// Close sends a close frame to the remote server and closes the connection.
+func (t *WSTransport) Close() error {
+	t.writeLock.Lock()
+	_ = t.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(wsCloseTimeout),
+	)
+	t.writeLock.Unlock()
+
+	return t.conn.Close()
+}
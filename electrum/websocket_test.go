@@ -0,0 +1,71 @@
+package electrum
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientWS(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	closed := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			// One JSON-RPC message per WebSocket message.
+			_, msg, err := conn.ReadMessage()
+			require.NoError(t, err)
+
+			var req request
+			require.NoError(t, json.Unmarshal(msg, &req))
+			assert.Equal(t, "server.ping", req.Method)
+
+			err = conn.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      req.ID,
+				"result":  nil,
+			})
+			require.NoError(t, err)
+
+			<-closed
+			conn.WriteMessage(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(
+					websocket.CloseGoingAway,
+					"restarting",
+				),
+			)
+		},
+	))
+	defer server.Close()
+
+	ctx := context.Background()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	client, err := NewClientWS(ctx, url, nil, WithTimeout(time.Second))
+	require.NoError(t, err)
+
+	require.NoError(t, client.Ping(ctx))
+
+	close(closed)
+	select {
+	case err := <-client.Error:
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("close frame not reported")
+	}
+}
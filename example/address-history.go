@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -16,15 +15,20 @@ func GetDetailedAddressHistory(address string) {
 		address = "3AwUscZWWWqgkEg3t4Xb9kY6c281KDwHW2"
 	}
 
+	// Servers mostly use self-signed certificates, trusted on first use.
+	certs, err := electrum.NewCertStore("certs")
+	if err != nil {
+		panic(err)
+	}
+
 	ctx := context.Background()
 	client, err := electrum.NewClientSSL(
 		ctx,
 		//"electrum.bitaroo.net:50002",
 		"ru.poiuty.com:50002",
-		&tls.Config{
-			InsecureSkipVerify: true,
-		},
+		nil,
 		electrum.WithTimeout(time.Second*10),
+		electrum.WithCertStore(certs),
 	)
 	if err != nil {
 		panic(err)
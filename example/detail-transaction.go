@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -16,15 +15,20 @@ func GetDetailedTransaction(txid string) {
 		txid = "66555dfb0f823623caae5ac27dc1458a78a1cfe36ab85792a05583453446d9e2"
 	}
 
+	// Servers mostly use self-signed certificates, trusted on first use.
+	certs, err := electrum.NewCertStore("certs")
+	if err != nil {
+		panic(err)
+	}
+
 	ctx := context.Background()
 	client, err := electrum.NewClientSSL(
 		ctx,
 		"electrum.bitaroo.net:50002",
 		//"ru.poiuty.com:50002",
-		&tls.Config{
-			InsecureSkipVerify: true,
-		},
+		nil,
 		electrum.WithTimeout(time.Second*10),
+		electrum.WithCertStore(certs),
 	)
 	if err != nil {
 		panic(err)
@@ -12,6 +12,9 @@ func TestServer() {
 	client, err := electrum.NewClientTCP(
 		context.Background(),
 		"bch.imaginary.cash:50001",
+		// Pings every minute, a ping unanswered within 10 seconds closes the
+		// connection and reports the error on client.Error.
+		electrum.WithKeepAlive(time.Minute, 10*time.Second),
 	)
 
 	if err != nil {
@@ -29,11 +32,6 @@ func TestServer() {
 	log.Printf("Server version: %s [Protocol %s]", serverVer, protocolVer)
 
 	go func() {
-		for {
-			if err := client.Ping(context.Background()); err != nil {
-				log.Fatal(err)
-			}
-			time.Sleep(60 * time.Second)
-		}
+		log.Fatal(<-client.Error)
 	}()
 }